@@ -0,0 +1,206 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// AtomicSnowflake is a lock-free alternative to Snowflake for callers with
+// many concurrent goroutines. The last timestamp and the sequence number are
+// packed into a single atomic word that is advanced with compare-and-swap,
+// so no mutex is taken on the hot path.
+//
+// It gives the same guarantees as Snowflake: IDs are unique and every ID is
+// greater than all IDs returned before GenerateID was called. Only the
+// RollbackFail strategy is supported, and checkpoints are not: persisting a
+// high-water mark would put a blocking save on the lock-free path.
+type AtomicSnowflake struct {
+	// The last timestamp shifted left by the sequence bits, ORed with the
+	// last sequence number.
+	state atomic.Int64
+
+	// The unique ID of this machine/generator instance.
+	machineID int64
+	// The bit layout and epoch of generated IDs.
+	layout Layout
+	// The time source.
+	clock Clock
+	// The epoch and tick in nanoseconds, cached from layout.
+	base timeBase
+	// The maximum sequence number, cached from layout.
+	maxSequence int64
+	// The maximum timestamp, cached from layout.
+	maxTimestamp int64
+	// The bit shift amount for the timestamp component.
+	timestampShift uint8
+	// The bit shift amount for the machine ID component.
+	machineIDShift uint8
+	// Receives instrumentation events, if set.
+	observer Observer
+	// Warns once the remaining lifetime of the layout runs low, if set.
+	lifetime *lifetimeWarning
+	// The lease on machineID, if the machine ID is leased.
+	lease MachineIDLease
+}
+
+// NewAtomicSnowflake creates a lock-free generator. It accepts the same
+// options as NewSnowflake but rejects rollback strategies other than
+// RollbackFail, WithSpareMachineIDs and WithCheckpoint.
+func NewAtomicSnowflake(machineID int64, opts ...Option) (*AtomicSnowflake, error) {
+	// Reuse NewSnowflake to apply and validate the options.
+	base, err := NewSnowflake(machineID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if base.rollbackStrategy != RollbackFail {
+		return nil, fmt.Errorf("AtomicSnowflake does not support rollback strategy %s", base.rollbackStrategy)
+	}
+	if len(base.spareMachineIDs) > 0 {
+		return nil, errors.New("AtomicSnowflake does not support spare machine IDs")
+	}
+	if base.checkpoint != nil {
+		return nil, errors.New("AtomicSnowflake does not support checkpoints")
+	}
+
+	s := &AtomicSnowflake{
+		machineID:      base.machineID,
+		layout:         base.layout,
+		clock:          base.clock,
+		base:           base.base,
+		maxSequence:    base.maxSequence,
+		maxTimestamp:   base.maxTimestamp,
+		timestampShift: base.timestampShift,
+		machineIDShift: base.machineIDShift,
+		observer:       base.observer,
+		lifetime:       base.lifetime,
+		lease:          base.lease,
+	}
+	// Start with a last timestamp of -1 to indicate no IDs generated yet.
+	s.state.Store(-1 << s.layout.SequenceBits)
+	return s, nil
+}
+
+// Layout returns the bit layout and epoch used by the generator.
+func (s *AtomicSnowflake) Layout() Layout {
+	return s.layout
+}
+
+// MachineID returns the machine ID embedded in every generated ID.
+func (s *AtomicSnowflake) MachineID() int64 {
+	return s.machineID
+}
+
+// LeaseErr returns the error of the generator's machine ID lease, or nil if
+// the lease is held or the generator has none.
+func (s *AtomicSnowflake) LeaseErr() error {
+	if s.lease == nil {
+		return nil
+	}
+	return s.lease.Err()
+}
+
+// Decode splits an ID generated by this generator into its components.
+func (s *AtomicSnowflake) Decode(id int64) (Parts, error) {
+	return s.layout.Decode(id)
+}
+
+// GenerateID creates and returns a new unique 64-bit Snowflake ID without
+// taking a lock.
+func (s *AtomicSnowflake) GenerateID() (int64, error) {
+	return s.generate(context.Background(), true)
+}
+
+// GenerateIDContext is like GenerateID but gives up with ctx's error
+// (wrapped) once ctx is done while waiting for the next tick.
+func (s *AtomicSnowflake) GenerateIDContext(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.generate(ctx, true)
+}
+
+// TryGenerateID is like GenerateID but returns ErrSequenceExhausted instead
+// of waiting for the next tick.
+func (s *AtomicSnowflake) TryGenerateID() (int64, error) {
+	return s.generate(context.Background(), false)
+}
+
+// generate issues one ID. Unless wait is set, it fails instead of waiting
+// for the next tick.
+func (s *AtomicSnowflake) generate(ctx context.Context, wait bool) (int64, error) {
+	// Stop issuing IDs as soon as the machine ID lease is lost.
+	if err := s.LeaseErr(); err != nil {
+		return 0, fmt.Errorf("machine ID %d: %w", s.machineID, err)
+	}
+	// When this call started waiting for the next tick, if it had to.
+	var waitStart time.Time
+	for {
+		// Unpack the last issued timestamp and sequence number.
+		old := s.state.Load()
+		lastTimestamp := old >> s.layout.SequenceBits
+		sequence := old & s.maxSequence
+
+		// Get the current time in ticks since the custom epoch.
+		currentTimestamp := s.base.ticks(s.clock.Now())
+
+		var next int64
+		switch {
+		case currentTimestamp < lastTimestamp:
+			// Refuse to generate an ID that could break monotonicity.
+			err := fmt.Errorf("%w: clock is %v behind the last issued timestamp",
+				ErrClockMovedBackwards, s.base.duration(lastTimestamp-currentTimestamp))
+			if s.observer != nil {
+				s.observer.ObserveRollback(RollbackEvent{
+					Strategy:         RollbackFail,
+					LastTimestamp:    lastTimestamp,
+					CurrentTimestamp: currentTimestamp,
+					Backwards:        s.base.duration(lastTimestamp - currentTimestamp),
+					MachineID:        s.machineID,
+					Err:              err,
+				})
+			}
+			return 0, err
+		case currentTimestamp == lastTimestamp:
+			if sequence == s.maxSequence {
+				// Sequence exhausted for this tick: wait for the next one
+				// and retry from the top.
+				if s.observer != nil && waitStart.IsZero() {
+					s.observer.ObserveSequenceOverflow()
+					waitStart = time.Now()
+				}
+				if !wait {
+					return 0, ErrSequenceExhausted
+				}
+				if err := PollClock(ctx, s.clock, s.base.duration(1)); err != nil {
+					return 0, fmt.Errorf("waiting for the next tick: %w", err)
+				}
+				continue
+			}
+			next = old + 1
+		case currentTimestamp > s.maxTimestamp:
+			// Refuse timestamps that would overflow into the sign bit.
+			return 0, fmt.Errorf("%w: timestamps ran out at %s", ErrEpochExhausted, s.layout.ExhaustionTime().Format(time.RFC3339Nano))
+		default:
+			// A new tick starts at sequence 0.
+			next = currentTimestamp << s.layout.SequenceBits
+		}
+
+		// Publish the new state; if another goroutine won the race, retry.
+		if !s.state.CompareAndSwap(old, next) {
+			continue
+		}
+		if s.observer != nil {
+			if !waitStart.IsZero() {
+				s.observer.ObserveTickWait(time.Since(waitStart))
+			}
+			s.observer.ObserveIDs(1)
+		}
+		s.lifetime.check(next >> s.layout.SequenceBits)
+		return (next>>s.layout.SequenceBits)<<s.timestampShift |
+			s.machineID<<s.machineIDShift |
+			next&s.maxSequence, nil
+	}
+}
@@ -0,0 +1,168 @@
+package snowflake
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchLayout has enough sequence bits that the benchmarks measure locking
+// overhead rather than the per-tick sequence limit of DefaultLayout.
+var benchLayout = Layout{TimestampBits: 40, WorkerBits: 3, SequenceBits: 20, Epoch: DefaultEpoch}
+
+// benchGoroutines are the numbers of goroutines calling GenerateID at once
+// that the benchmarks compare.
+var benchGoroutines = []int{1, 8, 64}
+
+// benchGenerate runs b.N calls of GenerateID of gen, spread over exactly
+// the goroutine counts of benchGoroutines regardless of GOMAXPROCS.
+func benchGenerate(b *testing.B, gen interface{ GenerateID() (int64, error) }) {
+	for _, goroutines := range benchGoroutines {
+		b.Run("goroutines="+strconv.Itoa(goroutines), func(b *testing.B) {
+			b.ReportAllocs()
+			var wg sync.WaitGroup
+			for g := range goroutines {
+				// The first goroutines take the remainder of the division.
+				calls := b.N / goroutines
+				if g < b.N%goroutines {
+					calls++
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range calls {
+						if _, err := gen.GenerateID(); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkSnowflake(b *testing.B) {
+	gen, err := NewSnowflake(1, WithLayout(benchLayout))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchGenerate(b, gen)
+}
+
+func BenchmarkAtomicSnowflake(b *testing.B) {
+	gen, err := NewAtomicSnowflake(1, WithLayout(benchLayout))
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchGenerate(b, gen)
+}
+
+func TestAtomicSnowflakeConcurrent(t *testing.T) {
+	clock := NewManualClock(testStart)
+	gen, err := NewAtomicSnowflake(1, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewAtomicSnowflake: %v", err)
+	}
+
+	// Enough IDs that the sequence of several ticks is used up.
+	const goroutines, perGoroutine = 64, 200
+	ids := make([][]int64, goroutines)
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perGoroutine {
+				id, err := gen.GenerateID()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				ids[g] = append(ids[g], id)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[int64]bool, goroutines*perGoroutine)
+	for g, got := range ids {
+		for i, id := range got {
+			if seen[id] {
+				t.Fatalf("ID %d issued twice", id)
+			}
+			seen[id] = true
+			if i > 0 && id <= got[i-1] {
+				t.Fatalf("goroutine %d got ID %d after %d", g, id, got[i-1])
+			}
+		}
+	}
+	if len(seen) != goroutines*perGoroutine {
+		t.Errorf("%d IDs issued, want %d", len(seen), goroutines*perGoroutine)
+	}
+	if !clock.Now().After(testStart.Add(2 * time.Millisecond)) {
+		t.Error("the clock did not move on after the sequence was exhausted")
+	}
+}
+
+func TestAtomicSnowflakeSequenceExhausted(t *testing.T) {
+	// 16 IDs per tick make the sequence easy to exhaust.
+	layout := Layout{TimestampBits: 49, WorkerBits: 10, SequenceBits: 4, Epoch: DefaultEpoch}
+	clock := NewManualClock(testStart)
+	gen, err := NewAtomicSnowflake(1, WithClock(clock), WithLayout(layout))
+	if err != nil {
+		t.Fatalf("NewAtomicSnowflake: %v", err)
+	}
+	for i := int64(0); i <= layout.MaxSequence(); i++ {
+		if _, err := gen.TryGenerateID(); err != nil {
+			t.Fatalf("TryGenerateID %d: %v", i, err)
+		}
+	}
+	if _, err := gen.TryGenerateID(); !errors.Is(err, ErrSequenceExhausted) {
+		t.Fatalf("TryGenerateID with the sequence exhausted: got %v, want ErrSequenceExhausted", err)
+	}
+	// The next tick has a fresh sequence.
+	clock.Advance(time.Millisecond)
+	if _, err := gen.TryGenerateID(); err != nil {
+		t.Errorf("TryGenerateID in the next tick: %v", err)
+	}
+}
+
+func TestAtomicSnowflakeClockMovedBackwards(t *testing.T) {
+	clock := NewManualClock(testStart)
+	gen, err := NewAtomicSnowflake(1, WithClock(clock))
+	if err != nil {
+		t.Fatalf("NewAtomicSnowflake: %v", err)
+	}
+	if _, err := gen.GenerateID(); err != nil {
+		t.Fatalf("GenerateID: %v", err)
+	}
+	clock.Advance(-time.Millisecond)
+	if _, err := gen.GenerateID(); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Errorf("GenerateID after a backwards jump: got %v, want ErrClockMovedBackwards", err)
+	}
+}
+
+func TestAtomicSnowflakeRejectsOptions(t *testing.T) {
+	cp := FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint")}
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"wait", WithRollbackStrategy(RollbackWait)},
+		{"borrow", WithRollbackStrategy(RollbackBorrow)},
+		{"spares", WithSpareMachineIDs(2)},
+		{"checkpoint", WithCheckpoint(cp, time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAtomicSnowflake(1, tt.opt); err == nil {
+				t.Error("NewAtomicSnowflake accepted the option")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package snowflake
+
+import (
+	"context"
+	"fmt"
+)
+
+// Range is a run of consecutive IDs from First to Last (inclusive). All IDs
+// of a range share the same timestamp and differ only in their sequence number.
+type Range struct {
+	First int64
+	Last  int64
+}
+
+// Len returns the number of IDs in the range.
+func (r Range) Len() int {
+	return int(r.Last - r.First + 1)
+}
+
+// Block is a set of IDs reserved from a generator in one critical section.
+// Its ranges are in ascending order; a block spans several ranges when it
+// does not fit into the sequence space left in one tick.
+type Block struct {
+	Ranges []Range
+}
+
+// Len returns the number of IDs in the block.
+func (b Block) Len() int {
+	n := 0
+	for _, r := range b.Ranges {
+		n += r.Len()
+	}
+	return n
+}
+
+// IDs expands the block into its individual IDs, in ascending order.
+func (b Block) IDs() []int64 {
+	ids := make([]int64, 0, b.Len())
+	for _, r := range b.Ranges {
+		for id := r.First; id <= r.Last; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Reserve hands out n IDs in a single critical section. The IDs of a block
+// are issued exactly as if GenerateID had been called n times without any
+// other caller in between: every ID in the block is greater than all IDs
+// issued by the generator before, and smaller than all IDs issued after, so
+// blocks (and single IDs) from the same generator never overlap.
+//
+// When the current tick runs out of sequence numbers the block continues in
+// the next tick, waiting for the clock like GenerateID.
+// If an error occurs part way, no block is returned and the IDs already
+// taken are never reissued.
+func (s *Snowflake) Reserve(n int) (Block, error) {
+	if n <= 0 {
+		return Block{}, fmt.Errorf("cannot reserve %d IDs", n)
+	}
+
+	s.mu.Lock()
+	var block Block
+	var events []RollbackEvent
+	var err error
+	for remaining := int64(n); remaining > 0; {
+		// Issue the first ID of the next run like GenerateID does; this takes
+		// care of new ticks, overflows and clock regressions.
+		var first int64
+		var event *RollbackEvent
+		first, event, err = s.generateLocked(context.Background(), true)
+		if event != nil {
+			events = append(events, *event)
+		}
+		if err != nil {
+			break
+		}
+		// Claim the rest of the run from the same tick's sequence space.
+		take := min(remaining-1, s.maxSequence-s.sequence)
+		s.sequence += take
+		block.Ranges = append(block.Ranges, Range{First: first, Last: first + take})
+		remaining -= take + 1
+	}
+	s.mu.Unlock()
+
+	// Surface clock regressions to the caller outside the lock.
+	s.reportRollbacks(events...)
+	if err != nil {
+		return Block{}, err
+	}
+	if s.observer != nil {
+		s.observer.ObserveIDs(n)
+	}
+	s.lifetime.check(block.Ranges[len(block.Ranges)-1].Last >> s.timestampShift)
+	return block, nil
+}
+
+// GenerateN returns n new IDs in ascending order, reserved as one Block.
+func (s *Snowflake) GenerateN(n int) ([]int64, error) {
+	block, err := s.Reserve(n)
+	if err != nil {
+		return nil, err
+	}
+	return block.IDs(), nil
+}
@@ -0,0 +1,49 @@
+package snowflake
+
+import "testing"
+
+func TestReserveAcrossTicks(t *testing.T) {
+	// 16 IDs per tick, so a block of 50 spans four ticks.
+	layout := Layout{TimestampBits: 49, WorkerBits: 10, SequenceBits: 4, Epoch: DefaultEpoch}
+	s, _ := newManualGenerator(t, WithLayout(layout))
+
+	before, err := s.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID: %v", err)
+	}
+	block, err := s.Reserve(50)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if block.Len() != 50 {
+		t.Fatalf("block has %d IDs, want 50", block.Len())
+	}
+	if len(block.Ranges) != 4 {
+		t.Errorf("block spans %d ranges, want 4", len(block.Ranges))
+	}
+
+	ids := block.IDs()
+	last := before
+	for i, id := range ids {
+		if id <= last {
+			t.Fatalf("ID %d of the block (%d) is not greater than %d", i, id, last)
+		}
+		last = id
+	}
+
+	next, err := s.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID after Reserve: %v", err)
+	}
+	if next <= last {
+		t.Errorf("ID after the block %d is not greater than the last reserved ID %d", next, last)
+	}
+
+	more, err := s.GenerateN(20)
+	if err != nil {
+		t.Fatalf("GenerateN: %v", err)
+	}
+	if more[0] <= next {
+		t.Errorf("GenerateN started at %d, not after %d", more[0], next)
+	}
+}
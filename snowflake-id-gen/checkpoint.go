@@ -0,0 +1,174 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCheckpointInterval is how far ahead of the clock a checkpointing
+// generator reserves timestamps unless configured otherwise.
+const DefaultCheckpointInterval = time.Second
+
+// ErrCheckpoint is returned when the generator cannot persist its checkpoint
+// and therefore refuses to issue IDs it could not protect across a restart.
+var ErrCheckpoint = errors.New("checkpoint failed")
+
+// Checkpointer durably stores a generator's high-water mark: a time such
+// that every ID issued so far has an earlier timestamp.
+type Checkpointer interface {
+	// Load returns the stored high-water mark. ok is false if none has been
+	// stored yet.
+	Load() (t time.Time, ok bool, err error)
+	// Save durably stores a new high-water mark before returning.
+	Save(t time.Time) error
+}
+
+// FileCheckpoint stores the high-water mark as Unix milliseconds in a local
+// file. Saves write a temporary file, sync it and rename it over Path, so a
+// crash leaves either the old or the new value.
+type FileCheckpoint struct {
+	Path string
+}
+
+// Load implements Checkpointer.
+func (f FileCheckpoint) Load() (time.Time, bool, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("corrupt checkpoint %s: %w", f.Path, err)
+	}
+	return time.UnixMilli(ms), true, nil
+}
+
+// Save implements Checkpointer.
+func (f FileCheckpoint) Save(t time.Time) error {
+	dir := filepath.Dir(f.Path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// Clean up the temporary file unless it was renamed into place.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(t.UnixMilli(), 10) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return err
+	}
+	// Sync the directory so the rename itself survives a crash.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// WithCheckpoint makes the generator survive restarts with a clock that is
+// behind where it was. On creation the generator loads the stored
+// high-water mark and never issues IDs below it. While running it reserves
+// windows of interval ahead of the clock, saving the end of each window
+// before issuing IDs inside it, so the cost is one save per interval.
+//
+// After an unclean restart the clock may be up to interval behind the
+// stored mark; the generator treats this like any other clock regression
+// (see WithRollbackStrategy). Close saves the exact mark so clean restarts
+// are not affected. A non-positive interval selects DefaultCheckpointInterval.
+//
+// Only the active machine ID's history is stored, so NewSnowflake rejects
+// checkpoints together with RollbackSwitchMachineID and spare machine IDs.
+func WithCheckpoint(cp Checkpointer, interval time.Duration) Option {
+	return func(s *Snowflake) {
+		if interval <= 0 {
+			interval = DefaultCheckpointInterval
+		}
+		s.checkpoint = cp
+		s.checkpointInterval = interval
+	}
+}
+
+// loadCheckpoint restores the high-water mark when the generator is created.
+func (s *Snowflake) loadCheckpoint() error {
+	mark, ok, err := s.checkpoint.Load()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCheckpoint, err)
+	}
+	if !ok {
+		return nil
+	}
+	// IDs up to the tick before the mark may have been issued: resume there
+	// with an exhausted sequence so the next ID moves on to the mark. Marks
+	// inside a tick are rounded up to be safe.
+	highWater := s.base.ticks(mark.Add(s.base.duration(1) - 1))
+	s.lastTimestamp = highWater - 1
+	s.sequence = s.maxSequence
+	s.reservedUntil = highWater
+	return nil
+}
+
+// reserve makes sure the checkpoint covers timestamp, saving a new window
+// if it does not. It must be called with the lock held.
+func (s *Snowflake) reserve(timestamp int64) error {
+	if s.checkpoint == nil || timestamp < s.reservedUntil {
+		return nil
+	}
+	until := timestamp + max(s.base.ticksCeil(s.checkpointInterval), 1)
+	if err := s.checkpoint.Save(s.base.time(until)); err != nil {
+		return fmt.Errorf("%w: %w", ErrCheckpoint, err)
+	}
+	s.reservedUntil = until
+	return nil
+}
+
+// Close saves the exact high-water mark of a checkpointing generator so that
+// a clean restart does not have to wait out the reserved window. IDs
+// generated after Close reserve a new window. Close is a no-op without a
+// checkpoint.
+func (s *Snowflake) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.checkpoint == nil {
+		return nil
+	}
+	// The mark never drops below an ID issued under any machine ID, and
+	// there is nothing to save if it is already exact or nothing was issued.
+	highest := s.highestTimestamp()
+	if highest < 0 || highest+1 >= s.reservedUntil {
+		return nil
+	}
+	if err := s.checkpoint.Save(s.base.time(highest + 1)); err != nil {
+		return fmt.Errorf("%w: %w", ErrCheckpoint, err)
+	}
+	s.reservedUntil = highest + 1
+	return nil
+}
+
+// highestTimestamp returns the highest timestamp issued by any machine ID the
+// generator has used, or -1 if it has not issued an ID. It must be called
+// with the lock held.
+func (s *Snowflake) highestTimestamp() int64 {
+	highest := s.lastTimestamp
+	for _, last := range s.machineIDLast {
+		highest = max(highest, last)
+	}
+	return highest
+}
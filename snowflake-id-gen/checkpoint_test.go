@@ -0,0 +1,82 @@
+package snowflake
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRejectsSpareMachineIDs(t *testing.T) {
+	cp := FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint")}
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"switch", []Option{WithRollbackStrategy(RollbackSwitchMachineID), WithSpareMachineIDs(2)}},
+		{"switch without spares", []Option{WithRollbackStrategy(RollbackSwitchMachineID)}},
+		{"spares", []Option{WithSpareMachineIDs(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]Option{WithCheckpoint(cp, time.Second)}, tt.opts...)
+			if _, err := NewSnowflake(1, opts...); err == nil {
+				t.Fatal("NewSnowflake accepted a checkpoint with spare machine IDs")
+			}
+		})
+	}
+}
+
+func TestCheckpointCloseAndRestart(t *testing.T) {
+	cp := FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint")}
+	clock := NewManualClock(testStart)
+	s, err := NewSnowflake(1, WithClock(clock), WithCheckpoint(cp, time.Second))
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	var last int64
+	for i := 0; i < 5; i++ {
+		if last, err = s.GenerateID(); err != nil {
+			t.Fatalf("GenerateID: %v", err)
+		}
+		clock.Advance(time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	parts, _ := s.Decode(last)
+	mark, ok, err := cp.Load()
+	if err != nil || !ok {
+		t.Fatalf("Load after Close: %v, %v", ok, err)
+	}
+	if want := parts.Time.Add(time.Millisecond); !mark.Equal(want) {
+		t.Errorf("mark after Close = %v, want %v", mark, want)
+	}
+
+	// A restart with the clock behind the last issued ID refuses to issue.
+	clock.Set(parts.Time.Add(-3 * time.Millisecond))
+	restarted, err := NewSnowflake(1, WithClock(clock), WithCheckpoint(cp, time.Second))
+	if err != nil {
+		t.Fatalf("NewSnowflake after restart: %v", err)
+	}
+	if _, err := restarted.GenerateID(); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Fatalf("GenerateID behind the mark: got %v, want ErrClockMovedBackwards", err)
+	}
+	// Closing without issuing an ID keeps the mark.
+	if err := restarted.Close(); err != nil {
+		t.Fatalf("Close after restart: %v", err)
+	}
+	if again, _, _ := cp.Load(); !again.Equal(mark) {
+		t.Errorf("mark after an idle Close = %v, want %v", again, mark)
+	}
+
+	// Once the clock reaches the mark, IDs continue above the last one.
+	clock.Set(mark)
+	id, err := restarted.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID at the mark: %v", err)
+	}
+	if id <= last {
+		t.Errorf("ID after restart %d is not greater than %d", id, last)
+	}
+}
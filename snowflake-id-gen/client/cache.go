@@ -0,0 +1,327 @@
+// Package client hands out Snowflake IDs from blocks cached in the calling
+// process, so that hot paths do not pay a network round-trip per ID.
+//
+// A Cache leases blocks of IDs from a Source, usually the GET /ids endpoint
+// of the ID service (HTTPSource) or, in tests, a local generator
+// (LocalSource). A background goroutine fetches the next block once the
+// cached IDs drop below a low-water mark, so callers normally never wait.
+// When the source fails, the Cache keeps serving the IDs it holds, tries an
+// optional fallback source and retries with exponential backoff.
+//
+// IDs of one block are ascending and blocks from the same service never
+// overlap, but IDs handed out by different caches (or by a fallback source
+// with another machine ID) do not sort by issue time. Cached IDs that are
+// never handed out, such as those left when a Cache is closed, are lost:
+// the service never reissues them.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// Defaults used when a Config field is left zero.
+const (
+	DefaultBlockSize    = 1000
+	DefaultFetchTimeout = 2 * time.Second
+	DefaultMinBackoff   = 100 * time.Millisecond
+	DefaultMaxBackoff   = 10 * time.Second
+)
+
+// Errors returned by a Cache.
+var (
+	// ErrUnavailable is returned when no IDs are cached and the last
+	// attempt to fetch a block failed. It wraps the fetch error.
+	ErrUnavailable = errors.New("no IDs available")
+	// ErrClosed is returned once the Cache has been closed.
+	ErrClosed = errors.New("ID cache closed")
+)
+
+// Config configures a Cache.
+type Config struct {
+	// Where blocks come from.
+	Source Source
+	// Tried when Source fails, e.g. a LocalSource with a machine ID
+	// reserved for fallback use. Optional.
+	Fallback Source
+	// IDs requested per fetch (DefaultBlockSize if zero).
+	BlockSize int
+	// The next block is fetched once fewer IDs are cached (a quarter of
+	// BlockSize if zero).
+	LowWater int
+	// How long a single fetch may take (DefaultFetchTimeout if zero).
+	FetchTimeout time.Duration
+	// The first and the largest delay between failed fetches
+	// (DefaultMinBackoff and DefaultMaxBackoff if zero).
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Stats counts the activity of a Cache.
+type Stats struct {
+	// IDs handed out.
+	Issued uint64
+	// IDs currently cached.
+	Cached int
+	// Blocks fetched from Source.
+	Fetches uint64
+	// Failed fetches from Source.
+	FetchFailures uint64
+	// Blocks fetched from Fallback after Source failed.
+	FallbackFetches uint64
+	// The error of the last failed refill, nil once a refill succeeds.
+	LastError error
+}
+
+// Cache serves IDs from locally cached blocks. It is safe for concurrent use.
+type Cache struct {
+	// The configuration, defaults applied.
+	cfg Config
+
+	// Protects everything below.
+	mu sync.Mutex
+	// The cached IDs, in the order they are handed out.
+	ids []int64
+	// The error of the last refill attempt, nil after a success.
+	err error
+	// Closed and replaced after every refill attempt, to wake waiting callers.
+	refilled chan struct{}
+	// Set by Close.
+	closed bool
+	// Activity counters.
+	stats Stats
+
+	// Wakes the refiller; buffered so that waking never blocks.
+	wake chan struct{}
+	// Stops the refiller.
+	cancel context.CancelFunc
+	// Closed when the refiller has stopped.
+	done chan struct{}
+}
+
+// New returns a Cache and starts fetching its first block in the
+// background. Close releases it.
+func New(cfg Config) (*Cache, error) {
+	if cfg.Source == nil {
+		return nil, errors.New("client: no source")
+	}
+	if cfg.BlockSize == 0 {
+		cfg.BlockSize = DefaultBlockSize
+	}
+	if cfg.LowWater == 0 {
+		cfg.LowWater = cfg.BlockSize / 4
+	}
+	if cfg.FetchTimeout == 0 {
+		cfg.FetchTimeout = DefaultFetchTimeout
+	}
+	if cfg.MinBackoff == 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff == 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	switch {
+	case cfg.BlockSize < 0:
+		return nil, fmt.Errorf("client: block size %d is negative", cfg.BlockSize)
+	case cfg.LowWater < 0 || cfg.LowWater >= cfg.BlockSize:
+		return nil, fmt.Errorf("client: low-water mark %d is not between 0 and the block size %d", cfg.LowWater, cfg.BlockSize)
+	case cfg.FetchTimeout < 0 || cfg.MinBackoff < 0 || cfg.MaxBackoff < cfg.MinBackoff:
+		return nil, errors.New("client: invalid fetch timeout or backoff")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Cache{
+		cfg:      cfg,
+		refilled: make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go c.refill(ctx)
+	return c, nil
+}
+
+// GenerateID returns the next cached ID. If none is cached it waits for the
+// refill in progress, which takes at most the fetch timeout per source.
+// It returns ErrUnavailable (wrapping the fetch error) while the sources
+// are failing and the cache is empty.
+func (c *Cache) GenerateID() (int64, error) {
+	return c.GenerateIDContext(context.Background())
+}
+
+// GenerateIDContext is like GenerateID but gives up with ctx's error
+// (wrapped) once ctx is done while waiting for a block.
+func (c *Cache) GenerateIDContext(ctx context.Context) (int64, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, ErrClosed
+		}
+		if len(c.ids) > 0 {
+			id := c.ids[0]
+			c.ids = c.ids[1:]
+			c.stats.Issued++
+			low := len(c.ids) < c.cfg.LowWater
+			c.mu.Unlock()
+			if low {
+				c.signal()
+			}
+			return id, nil
+		}
+		if c.err != nil {
+			// Fail fast while the sources are down rather than queueing
+			// callers behind the backoff.
+			err := c.err
+			c.mu.Unlock()
+			return 0, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		refilled := c.refilled
+		c.mu.Unlock()
+
+		c.signal()
+		select {
+		case <-refilled:
+		case <-ctx.Done():
+			return 0, fmt.Errorf("waiting for IDs: %w", ctx.Err())
+		}
+	}
+}
+
+// Scheme returns snowflake.SchemeSnowflake.
+func (c *Cache) Scheme() string {
+	return snowflake.SchemeSnowflake
+}
+
+// GenerateString returns the next cached ID in decimal form.
+func (c *Cache) GenerateString(ctx context.Context) (string, error) {
+	id, err := c.GenerateIDContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return snowflake.ID(id).String(), nil
+}
+
+// Stats returns the activity counters of the cache.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Cached = len(c.ids)
+	stats.LastError = c.err
+	return stats
+}
+
+// Close stops the background refill and discards the cached IDs. Callers
+// waiting for a block get ErrClosed.
+func (c *Cache) Close() error {
+	c.cancel()
+	<-c.done
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.ids = nil
+		close(c.refilled)
+	}
+	return nil
+}
+
+// signal wakes the refiller without blocking.
+func (c *Cache) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// refill runs until ctx is done, topping up the cache whenever it drops
+// below the low-water mark.
+func (c *Cache) refill(ctx context.Context) {
+	defer close(c.done)
+	var backoff time.Duration
+	for {
+		c.mu.Lock()
+		low := len(c.ids) < c.cfg.LowWater || len(c.ids) == 0
+		c.mu.Unlock()
+
+		if low {
+			ids, err := c.fetch(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			c.mu.Lock()
+			c.ids = append(c.ids, ids...)
+			c.err = err
+			close(c.refilled)
+			c.refilled = make(chan struct{})
+			c.mu.Unlock()
+
+			if err == nil {
+				backoff = 0
+				continue
+			}
+			// Back off before trying again.
+			backoff = min(max(2*backoff, c.cfg.MinBackoff), c.cfg.MaxBackoff)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+				continue
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		select {
+		case <-c.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fetch gets one block from the source, or from the fallback if the source
+// fails.
+func (c *Cache) fetch(ctx context.Context) ([]int64, error) {
+	ids, err := c.fetchFrom(ctx, c.cfg.Source)
+	c.mu.Lock()
+	if err != nil {
+		c.stats.FetchFailures++
+	} else {
+		c.stats.Fetches++
+	}
+	c.mu.Unlock()
+	if err == nil || c.cfg.Fallback == nil || ctx.Err() != nil {
+		return ids, err
+	}
+
+	ids, fallbackErr := c.fetchFrom(ctx, c.cfg.Fallback)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%w (fallback: %w)", err, fallbackErr)
+	}
+	c.mu.Lock()
+	c.stats.FallbackFetches++
+	c.mu.Unlock()
+	return ids, nil
+}
+
+// fetchFrom gets one block from source within the fetch timeout.
+func (c *Cache) fetchFrom(ctx context.Context, source Source) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.FetchTimeout)
+	defer cancel()
+	ids, err := source.Fetch(ctx, c.cfg.BlockSize)
+	if err == nil && len(ids) == 0 {
+		err = errors.New("source returned no IDs")
+	}
+	return ids, err
+}
+
+// Ensure Cache implements snowflake.IDGenerator.
+var _ snowflake.IDGenerator = (*Cache)(nil)
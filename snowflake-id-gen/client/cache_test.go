@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// errSourceDown is returned by failing test sources.
+var errSourceDown = errors.New("source down")
+
+// testSource wraps a Source, counting fetches and optionally failing them.
+type testSource struct {
+	// Serves the fetches that do not fail.
+	inner Source
+
+	mu sync.Mutex
+	// Fetch fails with errSourceDown while set.
+	down bool
+	// When each fetch started.
+	fetches []time.Time
+}
+
+// newLocalSource returns a testSource reserving blocks from a new generator
+// with the given machine ID.
+func newLocalSource(t *testing.T, machineID int64) *testSource {
+	t.Helper()
+	gen, err := snowflake.NewSnowflake(machineID)
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	return &testSource{inner: LocalSource{Generator: gen}}
+}
+
+func (s *testSource) Fetch(ctx context.Context, n int) ([]int64, error) {
+	s.mu.Lock()
+	s.fetches = append(s.fetches, time.Now())
+	down := s.down
+	s.mu.Unlock()
+	if down {
+		return nil, errSourceDown
+	}
+	return s.inner.Fetch(ctx, n)
+}
+
+// setDown makes fetches fail or succeed.
+func (s *testSource) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// fetchTimes returns when each fetch so far started.
+func (s *testSource) fetchTimes() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.fetches...)
+}
+
+// newCache returns a Cache that is closed when the test ends.
+func newCache(t *testing.T, cfg Config) *Cache {
+	t.Helper()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCacheRefillsBelowLowWater(t *testing.T) {
+	source := newLocalSource(t, 1)
+	c := newCache(t, Config{Source: source, BlockSize: 10, LowWater: 3})
+
+	var last int64
+	for i := 0; i < 7; i++ {
+		id, err := c.GenerateID()
+		if err != nil {
+			t.Fatalf("GenerateID %d: %v", i, err)
+		}
+		if id <= last {
+			t.Fatalf("ID %d is not greater than the previous %d", id, last)
+		}
+		last = id
+	}
+	// Three IDs are left, which is not below the low-water mark.
+	if stats := c.Stats(); stats.Fetches != 1 || stats.Cached != 3 {
+		t.Fatalf("Stats at the low-water mark = %+v, want 1 fetch and 3 cached", stats)
+	}
+
+	// Dropping below it fetches the next block in the background.
+	if _, err := c.GenerateID(); err != nil {
+		t.Fatalf("GenerateID: %v", err)
+	}
+	waitFor(t, "the second block", func() bool { return c.Stats().Fetches == 2 })
+	if stats := c.Stats(); stats.Cached != 12 || stats.Issued != 8 {
+		t.Errorf("Stats after the refill = %+v, want 12 cached and 8 issued", stats)
+	}
+	for i := 0; i < 12; i++ {
+		id, err := c.GenerateID()
+		if err != nil {
+			t.Fatalf("GenerateID after the refill: %v", err)
+		}
+		if id <= last {
+			t.Fatalf("ID %d is not greater than the previous %d", id, last)
+		}
+		last = id
+	}
+}
+
+func TestCacheFallback(t *testing.T) {
+	source := newLocalSource(t, 1)
+	source.setDown(true)
+	fallback := newLocalSource(t, 2)
+	c := newCache(t, Config{Source: source, Fallback: fallback, BlockSize: 10})
+
+	id, err := c.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID with the source down: %v", err)
+	}
+	if parts, _ := snowflake.Decode(id); parts.MachineID != 2 {
+		t.Errorf("ID came from machine ID %d, want the fallback's 2", parts.MachineID)
+	}
+	stats := c.Stats()
+	if stats.Fetches != 0 || stats.FetchFailures != 1 || stats.FallbackFetches != 1 || stats.LastError != nil {
+		t.Errorf("Stats = %+v, want one failure answered by the fallback", stats)
+	}
+}
+
+func TestCacheUnavailableBacksOff(t *testing.T) {
+	source := newLocalSource(t, 1)
+	source.setDown(true)
+	fallback := &testSource{down: true}
+	minBackoff, maxBackoff := 20*time.Millisecond, 40*time.Millisecond
+	c := newCache(t, Config{Source: source, Fallback: fallback, BlockSize: 10, MinBackoff: minBackoff, MaxBackoff: maxBackoff})
+
+	// Callers fail fast with both sources down.
+	waitFor(t, "the first failed fetch", func() bool { return c.Stats().LastError != nil })
+	_, err := c.GenerateID()
+	if !errors.Is(err, ErrUnavailable) || !errors.Is(err, errSourceDown) {
+		t.Fatalf("GenerateID with every source down: got %v, want ErrUnavailable wrapping the fetch error", err)
+	}
+
+	// Retries double the delay up to the maximum.
+	waitFor(t, "four fetch attempts", func() bool { return len(source.fetchTimes()) >= 4 })
+	times := source.fetchTimes()
+	for i, want := range []time.Duration{minBackoff, maxBackoff, maxBackoff} {
+		if gap := times[i+1].Sub(times[i]); gap < want {
+			t.Errorf("retry %d came after %v, want at least %v", i+1, gap, want)
+		}
+	}
+
+	// Once the source recovers, the next retry refills the cache.
+	source.setDown(false)
+	waitFor(t, "a successful fetch", func() bool { return c.Stats().Fetches == 1 })
+	if _, err := c.GenerateID(); err != nil {
+		t.Errorf("GenerateID after the source recovered: %v", err)
+	}
+	if err := c.Stats().LastError; err != nil {
+		t.Errorf("LastError after the source recovered = %v, want nil", err)
+	}
+}
+
+// blockingSource blocks every fetch until its context is done.
+type blockingSource struct {
+	// Receives a value when a fetch starts.
+	started chan struct{}
+}
+
+func (s blockingSource) Fetch(ctx context.Context, n int) ([]int64, error) {
+	s.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCacheClose(t *testing.T) {
+	source := blockingSource{started: make(chan struct{}, 1)}
+	c := newCache(t, Config{Source: source, BlockSize: 10, FetchTimeout: time.Minute})
+	<-source.started
+
+	// A caller waiting for the first block is released by Close.
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.GenerateID()
+		errc <- err
+	}()
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("waiting GenerateID after Close: got %v, want ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GenerateID still waiting after Close")
+	}
+	if _, err := c.GenerateID(); !errors.Is(err, ErrClosed) {
+		t.Errorf("GenerateID after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestCacheCloseStopsRefills(t *testing.T) {
+	// A failing source is retried every few milliseconds until Close.
+	source := newLocalSource(t, 1)
+	source.setDown(true)
+	c := newCache(t, Config{Source: source, BlockSize: 10, MinBackoff: 5 * time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	waitFor(t, "two fetch attempts", func() bool { return len(source.fetchTimes()) >= 2 })
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	fetches := len(source.fetchTimes())
+	time.Sleep(30 * time.Millisecond)
+	if got := len(source.fetchTimes()); got != fetches {
+		t.Errorf("%d fetches after Close, want %d", got, fetches)
+	}
+}
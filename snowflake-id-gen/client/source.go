@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// Source supplies blocks of IDs to a Cache.
+type Source interface {
+	// Fetch returns n new IDs in ascending order. It may return fewer, but
+	// never an empty block without an error.
+	Fetch(ctx context.Context, n int) ([]int64, error)
+}
+
+// HTTPSource fetches blocks from the GET /ids endpoint of an ID service
+// (package server, run by snowflaked or snowflake serve).
+type HTTPSource struct {
+	// The base URL of the service, e.g. http://ids.internal:8080.
+	BaseURL string
+	// The client used for requests (http.DefaultClient if nil).
+	Client *http.Client
+}
+
+// Fetch implements Source with GET /ids?count=n. n must not exceed the
+// service's -max-count.
+func (s HTTPSource) Fetch(ctx context.Context, n int) ([]int64, error) {
+	url := strings.TrimSuffix(s.BaseURL, "/") + "/ids?count=" + strconv.Itoa(n)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// The service explains failures in an {"error": "..."} body.
+		var body struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &body) != nil || body.Error == "" {
+			body.Error = strings.TrimSpace(string(data))
+		}
+		return nil, fmt.Errorf("GET %s: %s: %s", url, resp.Status, body.Error)
+	}
+
+	var body struct {
+		IDs []snowflake.ID `json:"ids"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("GET %s: decoding response: %w", url, err)
+	}
+	if len(body.IDs) == 0 {
+		return nil, fmt.Errorf("GET %s: no IDs in response", url)
+	}
+	ids := make([]int64, len(body.IDs))
+	for i, id := range body.IDs {
+		ids[i] = id.Int64()
+	}
+	return ids, nil
+}
+
+// LocalSource reserves blocks from an in-process generator, for tests and
+// as a fallback with a machine ID reserved for it.
+type LocalSource struct {
+	Generator *snowflake.Snowflake
+}
+
+// Fetch implements Source with Snowflake.Reserve.
+func (s LocalSource) Fetch(ctx context.Context, n int) ([]int64, error) {
+	if s.Generator == nil {
+		return nil, errors.New("local source has no generator")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	block, err := s.Generator.Reserve(n)
+	if err != nil {
+		return nil, err
+	}
+	return block.IDs(), nil
+}
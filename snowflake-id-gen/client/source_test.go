@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/server"
+)
+
+// newHTTPSource serves a generator with machine ID 7 over HTTP and returns a
+// source fetching from it.
+func newHTTPSource(t *testing.T, maxCount int) HTTPSource {
+	t.Helper()
+	gen, err := snowflake.NewSnowflake(7)
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	srv := httptest.NewServer(server.NewHTTPHandler(gen, maxCount))
+	t.Cleanup(srv.Close)
+	return HTTPSource{BaseURL: srv.URL + "/", Client: srv.Client()}
+}
+
+func TestHTTPSourceFetch(t *testing.T) {
+	source := newHTTPSource(t, 100)
+	ids, err := source.Fetch(context.Background(), 50)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(ids) != 50 {
+		t.Fatalf("Fetch returned %d IDs, want 50", len(ids))
+	}
+	for i, id := range ids {
+		if parts, _ := snowflake.Decode(id); parts.MachineID != 7 {
+			t.Errorf("ID %d has machine ID %d, want 7", id, parts.MachineID)
+		}
+		if i > 0 && id <= ids[i-1] {
+			t.Errorf("ID %d is not greater than the previous %d", id, ids[i-1])
+		}
+	}
+
+	// The service's error message is passed on.
+	_, err = source.Fetch(context.Background(), 101)
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "count must be an integer between 1 and 100") {
+		t.Errorf("Fetch above the maximum count: got %v, want the service's 400 error", err)
+	}
+}
+
+func TestCacheOverHTTP(t *testing.T) {
+	c := newCache(t, Config{Source: newHTTPSource(t, 100), BlockSize: 20})
+	seen := make(map[int64]bool)
+	for i := 0; i < 100; i++ {
+		id, err := c.GenerateID()
+		if err != nil {
+			t.Fatalf("GenerateID %d: %v", i, err)
+		}
+		if seen[id] {
+			t.Fatalf("ID %d handed out twice", id)
+		}
+		seen[id] = true
+	}
+	if stats := c.Stats(); stats.Fetches < 5 || stats.FetchFailures != 0 {
+		t.Errorf("Stats = %+v, want at least 5 fetches and no failures", stats)
+	}
+}
@@ -0,0 +1,150 @@
+package snowflake
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is the time source of a generator.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+}
+
+// Sleeper is implemented by clocks that control how waiting for time to pass
+// works. Generators use it, when available, instead of time.Sleep and
+// instead of spinning on Now while waiting for the next tick.
+type Sleeper interface {
+	// Sleep blocks until d has elapsed on the clock.
+	Sleep(d time.Duration)
+}
+
+// PollClock is one step of waiting for clock to reach a later time. It
+// returns ctx's error once ctx is done. Otherwise it sleeps for d if clock
+// is a Sleeper, or returns at once so the caller re-reads the clock.
+func PollClock(ctx context.Context, clock Clock, d time.Duration) error {
+	// Background contexts have no Done channel and are never checked.
+	if done := ctx.Done(); done != nil {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+	}
+	if sleeper, ok := clock.(Sleeper); ok {
+		sleeper.Sleep(d)
+	}
+	return nil
+}
+
+// WallClock reads the system wall clock with time.Now. It follows every
+// adjustment of the system clock, including NTP steps backwards.
+type WallClock struct{}
+
+// Now returns time.Now().
+func (WallClock) Now() time.Time {
+	return time.Now()
+}
+
+// MonotonicClock anchors the wall clock once at construction and afterwards
+// only advances with Go's monotonic clock, so wall-clock jumps after startup
+// (in either direction) are ignored. The trade-off is that genuine
+// corrections of a wrong startup time are ignored too.
+type MonotonicClock struct {
+	// The wall and monotonic reading taken at construction.
+	start time.Time
+}
+
+// NewMonotonicClock returns a MonotonicClock anchored at the current time.
+func NewMonotonicClock() *MonotonicClock {
+	return &MonotonicClock{start: time.Now()}
+}
+
+// Now returns the anchor time plus the monotonic time elapsed since then.
+func (c *MonotonicClock) Now() time.Time {
+	// time.Since uses the monotonic reading embedded in start.
+	return c.start.Round(0).Add(time.Since(c.start))
+}
+
+// ManualClock is a fake clock for tests. It only moves when told to: by Set,
+// by Advance, by Sleep, or by a fixed step on every call to Now.
+type ManualClock struct {
+	// Mutex to protect concurrent access to the current time.
+	mu sync.Mutex
+	// The current time.
+	now time.Time
+	// How far Now advances the clock after every call.
+	step time.Duration
+}
+
+// NewManualClock returns a ManualClock set to t.
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{now: t}
+}
+
+// Now returns the current time of the clock and then advances it by the step.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Set moves the clock to t, which may be before the current time.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock by d, which may be negative.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SetStep makes every call to Now advance the clock by d afterwards.
+func (c *ManualClock) SetStep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.step = d
+}
+
+// Sleep advances the clock by d without blocking.
+func (c *ManualClock) Sleep(d time.Duration) {
+	if d > 0 {
+		c.Advance(d)
+	}
+}
+
+// WithClock makes the generator read time from clock instead of the system
+// wall clock.
+func WithClock(clock Clock) Option {
+	return func(s *Snowflake) {
+		s.clock = clock
+	}
+}
+
+// currentTimestamp returns the current time in ticks since the epoch.
+func (s *Snowflake) currentTimestamp() int64 {
+	return s.base.ticks(s.clock.Now())
+}
+
+// sleep waits for d on the generator's clock, or until ctx is done.
+func (s *Snowflake) sleep(ctx context.Context, d time.Duration) error {
+	if sleeper, ok := s.clock.(Sleeper); ok {
+		sleeper.Sleep(d)
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
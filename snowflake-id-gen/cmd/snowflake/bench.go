@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+	"github.com/abkolan/snowflake-id-gen/ulid"
+	"github.com/abkolan/snowflake-id-gen/uuidv7"
+)
+
+// generator is the method set shared by Snowflake, AtomicSnowflake and Pool.
+type generator interface {
+	GenerateID() (int64, error)
+}
+
+// generatorFunc adapts the binary Generate methods of other ID schemes to
+// generator; the IDs themselves are discarded.
+type generatorFunc func() (int64, error)
+
+// GenerateID calls f.
+func (f generatorFunc) GenerateID() (int64, error) {
+	return f()
+}
+
+// runBench implements the bench subcommand: it compares the throughput of
+// the mutex based Snowflake, the lock-free AtomicSnowflake, sharded Pools and
+// the ULID and UUIDv7 generators at several goroutine counts. For precise
+// numbers run the BenchmarkSnowflake and BenchmarkAtomicSnowflake
+// benchmarks of the snowflake package with go test.
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	resolveLayout := cli.LayoutFlags(fs)
+	goroutinesFlag := fs.String("goroutines", "1,8,64", "comma separated goroutine counts")
+	duration := fs.Duration("duration", time.Second, "how long to run each benchmark")
+	shards := fs.Int("shards", 4, "number of shards of the pool generators")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+
+	layout, err := resolveLayout()
+	if err != nil {
+		return cli.Configf("parsing layout: %w", err)
+	}
+	counts, err := parseInts(*goroutinesFlag)
+	if err != nil {
+		return cli.Configf("parsing goroutine counts: %w", err)
+	}
+	if *duration <= 0 || *shards <= 0 {
+		return cli.Configf("-duration and -shards must be positive")
+	}
+
+	// Generator constructors under comparison.
+	impls := []struct {
+		name string
+		new  func() (generator, error)
+	}{
+		{"mutex", func() (generator, error) { return snowflake.NewSnowflake(1, snowflake.WithLayout(layout)) }},
+		{"atomic", func() (generator, error) { return snowflake.NewAtomicSnowflake(1, snowflake.WithLayout(layout)) }},
+		{fmt.Sprintf("pool-rr(%d)", *shards), func() (generator, error) {
+			return snowflake.NewPool(1, *shards, snowflake.RouteRoundRobin, snowflake.WithLayout(layout))
+		}},
+		{fmt.Sprintf("pool-affinity(%d)", *shards), func() (generator, error) {
+			return snowflake.NewPool(1, *shards, snowflake.RouteAffinity, snowflake.WithLayout(layout))
+		}},
+		{ulid.Scheme, func() (generator, error) {
+			gen := ulid.New()
+			return generatorFunc(func() (int64, error) {
+				_, err := gen.Generate()
+				return 0, err
+			}), nil
+		}},
+		{uuidv7.Scheme, func() (generator, error) {
+			gen := uuidv7.New()
+			return generatorFunc(func() (int64, error) {
+				_, err := gen.Generate()
+				return 0, err
+			}), nil
+		}},
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "IMPL\tGOROUTINES\tN\tNS/OP\tIDS/S\t")
+	for _, impl := range impls {
+		for _, g := range counts {
+			gen, err := impl.new()
+			if err != nil {
+				return cli.Configf("creating %s generator: %w", impl.name, err)
+			}
+			n, elapsed, err := measure(gen, g, *duration)
+			if err != nil {
+				return fmt.Errorf("%s with %d goroutines: %w", impl.name, g, err)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.0f\t\n", impl.name, g, n, elapsed.Nanoseconds()/n,
+				float64(n)/elapsed.Seconds())
+		}
+	}
+	return tw.Flush()
+}
+
+// measure calls GenerateID from the given number of goroutines for about
+// d and returns the number of IDs issued and the time it took. It stops
+// early with the first error.
+func measure(gen generator, goroutines int, d time.Duration) (int64, time.Duration, error) {
+	var (
+		stop     atomic.Bool
+		issued   atomic.Int64
+		errOnce  sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	start := time.Now()
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int64
+			for !stop.Load() {
+				if _, err := gen.GenerateID(); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					stop.Store(true)
+					break
+				}
+				n++
+			}
+			issued.Add(n)
+		}()
+	}
+	timer := time.AfterFunc(d, func() { stop.Store(true) })
+	wg.Wait()
+	timer.Stop()
+	elapsed := time.Since(start)
+
+	if firstErr != nil {
+		return 0, 0, firstErr
+	}
+	if issued.Load() == 0 {
+		return 0, 0, fmt.Errorf("no IDs issued in %v", elapsed)
+	}
+	return issued.Load(), elapsed, nil
+}
+
+// parseInts parses a comma separated list of positive integers.
+func parseInts(s string) ([]int, error) {
+	var out []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("%d is not positive", n)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
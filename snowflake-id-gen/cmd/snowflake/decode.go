@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+)
+
+// decodedID is the JSON representation of a decoded ID. The datacenter ID is
+// only present when the layout has datacenter bits.
+type decodedID struct {
+	ID           int64     `json:"id"`
+	Time         time.Time `json:"time"`
+	Timestamp    int64     `json:"timestamp"`
+	MachineID    int64     `json:"machine_id"`
+	DatacenterID *int64    `json:"datacenter_id,omitempty"`
+	WorkerID     int64     `json:"worker_id"`
+	Sequence     int64     `json:"sequence"`
+}
+
+// runDecode implements the decode subcommand. IDs are taken from the command
+// line or, if none are given, read whitespace separated from stdin.
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ContinueOnError)
+	resolveLayout := cli.LayoutFlags(fs)
+	format := fs.String("format", "dec", "input format: "+formatNames(idParsers))
+	asJSON := fs.Bool("json", false, "print one JSON object per ID instead of a table")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+
+	layout, err := resolveLayout()
+	if err != nil {
+		return cli.Configf("parsing layout: %w", err)
+	}
+	parse, ok := idParsers[*format]
+	if !ok {
+		return cli.Configf("unknown format %q (want %s)", *format, formatNames(idParsers))
+	}
+
+	// Collect the raw IDs from the arguments or stdin.
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs, err = readWords(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("reading IDs from stdin: %w", err)
+		}
+	}
+
+	// Decode every ID, counting the ones that failed.
+	failed := 0
+	decoded := make([]snowflake.Parts, 0, len(inputs))
+	for _, in := range inputs {
+		id, err := parse(in)
+		if err == nil {
+			var parts snowflake.Parts
+			parts, err = layout.Decode(id.Int64())
+			if err == nil {
+				decoded = append(decoded, parts)
+				continue
+			}
+		}
+		log.Printf("Error decoding '%s': %v", in, err)
+		failed++
+	}
+
+	if *asJSON {
+		err = printDecodedJSON(os.Stdout, layout, decoded)
+	} else {
+		err = printDecodedTable(os.Stdout, layout, decoded)
+	}
+	if err != nil {
+		return fmt.Errorf("writing decoded IDs: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d IDs could not be decoded", failed, len(inputs))
+	}
+	return nil
+}
+
+// printDecodedTable writes the decoded IDs as an aligned table.
+func printDecodedTable(w io.Writer, layout snowflake.Layout, decoded []snowflake.Parts) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	hasDatacenter := layout.DatacenterBits > 0
+
+	// Header row; the datacenter column is only shown when meaningful.
+	fmt.Fprint(tw, "ID\tTIME\tMACHINE")
+	if hasDatacenter {
+		fmt.Fprint(tw, "\tDATACENTER\tWORKER")
+	}
+	fmt.Fprintln(tw, "\tSEQUENCE")
+
+	for _, p := range decoded {
+		fmt.Fprintf(tw, "%d\t%s\t%d", p.ID, p.Time.Format("2006-01-02T15:04:05.000Z07:00"), p.MachineID)
+		if hasDatacenter {
+			fmt.Fprintf(tw, "\t%d\t%d", p.DatacenterID, p.WorkerID)
+		}
+		fmt.Fprintf(tw, "\t%d\n", p.Sequence)
+	}
+	return tw.Flush()
+}
+
+// printDecodedJSON writes the decoded IDs as JSON lines.
+func printDecodedJSON(w io.Writer, layout snowflake.Layout, decoded []snowflake.Parts) error {
+	enc := json.NewEncoder(w)
+	for _, p := range decoded {
+		out := decodedID{
+			ID:        p.ID,
+			Time:      p.Time,
+			Timestamp: p.Timestamp,
+			MachineID: p.MachineID,
+			WorkerID:  p.WorkerID,
+			Sequence:  p.Sequence,
+		}
+		if layout.DatacenterBits > 0 {
+			out.DatacenterID = &p.DatacenterID
+		}
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readWords reads whitespace separated words from r.
+func readWords(r io.Reader) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	return words, scanner.Err()
+}
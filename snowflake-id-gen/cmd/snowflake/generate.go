@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/idgen"
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+)
+
+// idFormatters maps the names accepted by -format to ID encodings.
+var idFormatters = map[string]func(snowflake.ID) string{
+	"dec":    snowflake.ID.String,
+	"hex":    snowflake.ID.Hex,
+	"base62": snowflake.ID.Base62,
+	"base32": snowflake.ID.Base32,
+}
+
+// idParsers maps the names accepted by -format to ID decodings.
+var idParsers = map[string]func(string) (snowflake.ID, error){
+	"dec":    snowflake.ParseID,
+	"hex":    snowflake.ParseHex,
+	"base62": snowflake.ParseBase62,
+	"base32": snowflake.ParseBase32,
+}
+
+// runGenerate implements the generate subcommand: it prints -count new IDs,
+// one per line, in the selected format.
+func runGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	resolveLayout := cli.LayoutFlags(fs)
+	resolveMachineID := cli.MachineIDFlags(fs)
+	scheme := fs.String("scheme", snowflake.SchemeSnowflake, "ID scheme: "+strings.Join(idgen.Schemes, ", "))
+	count := fs.Int("count", 1, "number of IDs to generate")
+	format := fs.String("format", "dec", "output format: "+formatNames(idFormatters)+" or json (other schemes: text or json)")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+
+	// Validate the flags before touching any generator.
+	if fs.NArg() > 0 {
+		return cli.Configf("unexpected arguments: %v", fs.Args())
+	}
+	if *count <= 0 {
+		return cli.Configf("-count must be positive, got %d", *count)
+	}
+	// Scheme names are case-insensitive, as in idgen.New.
+	schemeName := strings.ToLower(strings.TrimSpace(*scheme))
+	if schemeName != snowflake.SchemeSnowflake {
+		return generateOther(schemeName, *count, *format)
+	}
+	formatter, ok := idFormatters[*format]
+	if !ok && *format != "json" {
+		return cli.Configf("unknown format %q (want %s or json)", *format, formatNames(idFormatters))
+	}
+	layout, err := resolveLayout()
+	if err != nil {
+		return cli.Configf("parsing layout: %w", err)
+	}
+	machineID, _, err := resolveMachineID(layout)
+	if err != nil {
+		return cli.Configf("deriving machine ID: %w", err)
+	}
+	generator, err := snowflake.NewSnowflake(machineID, snowflake.WithLayout(layout))
+	if err != nil {
+		return cli.Configf("creating Snowflake generator: %w", err)
+	}
+
+	ids, err := generator.GenerateN(*count)
+	if err != nil {
+		return fmt.Errorf("generating IDs: %w", err)
+	}
+
+	// Print the IDs, one per line.
+	out := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(out)
+	for _, id := range ids {
+		if formatter == nil {
+			err = enc.Encode(struct {
+				ID snowflake.ID `json:"id"`
+			}{snowflake.ID(id)})
+		} else {
+			_, err = fmt.Fprintln(out, formatter(snowflake.ID(id)))
+		}
+		if err != nil {
+			return fmt.Errorf("writing IDs: %w", err)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("writing IDs: %w", err)
+	}
+	return nil
+}
+
+// generateOther prints count IDs of a scheme other than Snowflake in its
+// text form, or as JSON objects if format is json.
+func generateOther(scheme string, count int, format string) error {
+	// The snowflake default "dec" means the scheme's own text form.
+	if format != "dec" && format != "text" && format != "json" {
+		return cli.Configf("format %q is not available for %s (want text or json)", format, scheme)
+	}
+	generator, err := idgen.New(idgen.Config{Scheme: scheme})
+	if err != nil {
+		return cli.Config(err)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(out)
+	for i := 0; i < count; i++ {
+		id, err := generator.GenerateString(context.Background())
+		if err != nil {
+			return fmt.Errorf("generating IDs: %w", err)
+		}
+		if format == "json" {
+			err = enc.Encode(struct {
+				ID string `json:"id"`
+			}{id})
+		} else {
+			_, err = fmt.Fprintln(out, id)
+		}
+		if err != nil {
+			return fmt.Errorf("writing IDs: %w", err)
+		}
+	}
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("writing IDs: %w", err)
+	}
+	return nil
+}
+
+// formatNames returns the sorted keys of a format map, comma separated.
+func formatNames[F any](formats map[string]F) string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+)
+
+// layoutInfo is the JSON representation of an inspected layout.
+type layoutInfo struct {
+	Layout           string    `json:"layout"`
+	Epoch            time.Time `json:"epoch"`
+	Tick             string    `json:"tick"`
+	TimestampBits    uint8     `json:"timestamp_bits"`
+	DatacenterBits   uint8     `json:"datacenter_bits"`
+	WorkerBits       uint8     `json:"worker_bits"`
+	SequenceBits     uint8     `json:"sequence_bits"`
+	LifespanYears    float64   `json:"lifespan_years"`
+	Exhaustion       time.Time `json:"exhaustion"`
+	RemainingYears   float64   `json:"remaining_years"`
+	MachineIDs       int64     `json:"machine_ids"`
+	Datacenters      int64     `json:"datacenters"`
+	Workers          int64     `json:"workers"`
+	IDsPerTick       int64     `json:"ids_per_tick"`
+	IDsPerSecond     float64   `json:"ids_per_second"`
+	PeakIDsPerSecond float64   `json:"peak_ids_per_second"`
+}
+
+// errLifetimeTooShort is returned when -min-years is not met, so that the
+// exit status is 1.
+var errLifetimeTooShort = errors.New("layout lifetime too short")
+
+// hoursPerYear is the length of a Julian year in hours.
+const hoursPerYear = 365.25 * 24
+
+// runInspectLayout implements the inspect-layout subcommand: it describes
+// the bit split, lifetime and capacity of a layout.
+func runInspectLayout(args []string) error {
+	fs := flag.NewFlagSet("inspect-layout", flag.ContinueOnError)
+	resolveLayout := cli.LayoutFlags(fs)
+	asJSON := fs.Bool("json", false, "print a JSON object instead of a table")
+	minYears := fs.Float64("min-years", 0, "fail if fewer years are left before the timestamp is exhausted")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.Configf("unexpected arguments: %v", fs.Args())
+	}
+	layout, err := resolveLayout()
+	if err != nil {
+		return cli.Configf("parsing layout: %w", err)
+	}
+
+	info := layoutInfo{
+		Layout:           layout.BitString(),
+		Epoch:            layout.Epoch.UTC(),
+		Tick:             layout.TickDuration().String(),
+		TimestampBits:    layout.TimestampBits,
+		DatacenterBits:   layout.DatacenterBits,
+		WorkerBits:       layout.WorkerBits,
+		SequenceBits:     layout.SequenceBits,
+		LifespanYears:    layout.Lifespan().Hours() / hoursPerYear,
+		Exhaustion:       layout.ExhaustionTime(),
+		RemainingYears:   layout.Remaining(time.Now()).Hours() / hoursPerYear,
+		MachineIDs:       layout.MaxMachineID() + 1,
+		Datacenters:      layout.MaxDatacenterID() + 1,
+		Workers:          layout.MaxWorkerID() + 1,
+		IDsPerTick:       layout.IDsPerTick(),
+		IDsPerSecond:     layout.IDsPerSecond(),
+		PeakIDsPerSecond: layout.IDsPerSecond() * float64(layout.MaxMachineID()+1),
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			return err
+		}
+	} else if err := printLayoutInfo(layout, info); err != nil {
+		return err
+	}
+	if info.RemainingYears < *minYears {
+		return fmt.Errorf("%w: %.1f years left, want at least %g", errLifetimeTooShort, info.RemainingYears, *minYears)
+	}
+	return nil
+}
+
+// printLayoutInfo prints an inspected layout as a table.
+func printLayoutInfo(layout snowflake.Layout, info layoutInfo) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "layout\t%s\n", info.Layout)
+	fmt.Fprintf(tw, "epoch\t%s\n", info.Epoch.Format(time.RFC3339))
+	fmt.Fprintf(tw, "tick\t%s\n", info.Tick)
+	fmt.Fprintf(tw, "timestamp\t%d bits, lifespan %.1f years\n", info.TimestampBits, info.LifespanYears)
+	fmt.Fprintf(tw, "exhausted\t%s (%.1f years left)\n", info.Exhaustion.Format(time.RFC3339), info.RemainingYears)
+	if layout.DatacenterBits > 0 {
+		fmt.Fprintf(tw, "datacenter\t%d bits, %d datacenters\n", info.DatacenterBits, info.Datacenters)
+	}
+	fmt.Fprintf(tw, "worker\t%d bits, %d workers\n", info.WorkerBits, info.Workers)
+	fmt.Fprintf(tw, "sequence\t%d bits, %d IDs per tick\n", info.SequenceBits, info.IDsPerTick)
+	fmt.Fprintf(tw, "machine IDs\t%d\n", info.MachineIDs)
+	fmt.Fprintf(tw, "throughput\t%.0f IDs per second per machine\n", info.IDsPerSecond)
+	fmt.Fprintf(tw, "peak throughput\t%.0f IDs per second with every machine ID in use\n", info.PeakIDsPerSecond)
+	return tw.Flush()
+}
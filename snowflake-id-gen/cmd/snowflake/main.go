@@ -0,0 +1,102 @@
+// Command snowflake generates, decodes and benchmarks Snowflake IDs and can
+// serve them over HTTP.
+//
+// Usage:
+//
+//	snowflake generate [-scheme snowflake|ulid|uuidv7] [-count N] [-format dec|hex|base62|base32|json] [generator flags]
+//	snowflake decode [-format dec|hex|base62|base32] [-json] [layout flags] [id ...]
+//	snowflake inspect-layout [-json] [-min-years Y] [layout flags]
+//	snowflake bench [-goroutines 1,8,64] [-duration 1s] [-shards 4] [layout flags]
+//	snowflake simulate [-nodes N] [-machine-ids leased|mac] [-rollback S] [-json] [simulation flags] [layout flags]
+//	snowflake obfuscate [-key-file F] [-format dec|hex|base62|base32] [id ...]
+//	snowflake reveal [-key-file F] [-decode] [-json] [layout flags] [token ...]
+//	snowflake list-workers -registry-dir DIR [-live] [-json]
+//	snowflake serve [flags of snowflaked]
+//
+// Layout flags are -layout, -epoch and -tick; generator flags add the
+// machine ID flags -machine-id, -machine-id-provider, -machine-id-env and
+// -machine-id-file. obfuscate and reveal read their keys from -key-file or
+// $SNOWFLAKE_OBFUSCATION_KEYS. Flags may be written with one or two dashes.
+//
+// The exit status is 0 on success, 1 if generating, decoding or serving IDs
+// failed or a simulation found duplicate IDs, and 2 on invalid flags, arguments or configuration.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+	"github.com/abkolan/snowflake-id-gen/internal/daemon"
+)
+
+// command is a subcommand of the binary.
+type command struct {
+	// The name used on the command line.
+	name string
+	// One line description for the usage message.
+	summary string
+	// Runs the subcommand with the arguments following its name.
+	run func(args []string) error
+}
+
+// commands lists the subcommands in the order shown by the usage message.
+var commands = []command{
+	{"generate", "generate new IDs", runGenerate},
+	{"decode", "split IDs into timestamp, machine ID and sequence", runDecode},
+	{"inspect-layout", "describe the capacity of a bit layout", runInspectLayout},
+	{"bench", "compare generator implementations", runBench},
+	{"simulate", "check ID uniqueness across a simulated fleet", runSimulate},
+	{"obfuscate", "turn IDs into opaque tokens for public use", runObfuscate},
+	{"reveal", "turn opaque tokens back into IDs", runReveal},
+	{"list-workers", "list the machine IDs registered by running generators", runListWorkers},
+	{"serve", "serve IDs over HTTP (same flags as snowflaked)", func(args []string) error {
+		return daemon.Run("snowflake serve", args)
+	}},
+}
+
+// main is the entry point of the program.
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(cli.ExitConfig)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage(os.Stdout)
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			// The flag package has already printed usage for -h.
+			if !errors.Is(err, flag.ErrHelp) {
+				log.Printf("Error: %v", err)
+			}
+			os.Exit(cli.ExitCode(err))
+		}
+		return
+	}
+	log.Printf("Error: unknown command %q", name)
+	usage(os.Stderr)
+	os.Exit(cli.ExitConfig)
+}
+
+// usage prints the list of subcommands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: snowflake <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "snowflake <command> -h" for the flags of a command.`)
+}
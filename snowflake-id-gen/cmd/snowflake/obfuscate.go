@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+)
+
+// runObfuscate implements the obfuscate subcommand: it prints the opaque
+// token of every ID given on the command line or read from stdin.
+func runObfuscate(args []string) error {
+	fs := flag.NewFlagSet("obfuscate", flag.ContinueOnError)
+	resolveKeyring := cli.KeyringFlags(fs)
+	format := fs.String("format", "dec", "input format: "+formatNames(idParsers))
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	keyring, err := resolveKeyring()
+	if err != nil {
+		return cli.Config(err)
+	}
+	parse, ok := idParsers[*format]
+	if !ok {
+		return cli.Configf("unknown format %q (want %s)", *format, formatNames(idParsers))
+	}
+	inputs, err := argsOrStdin(fs)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, in := range inputs {
+		id, err := parse(in)
+		if err == nil {
+			var token string
+			token, err = keyring.Obfuscate(id.Int64())
+			if err == nil {
+				fmt.Println(token)
+				continue
+			}
+		}
+		log.Printf("Error obfuscating '%s': %v", in, err)
+		failed++
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d IDs could not be obfuscated", failed, len(inputs))
+	}
+	return nil
+}
+
+// runReveal implements the reveal subcommand: it turns tokens back into IDs
+// and, with -decode or -json, splits them into their components.
+func runReveal(args []string) error {
+	fs := flag.NewFlagSet("reveal", flag.ContinueOnError)
+	resolveKeyring := cli.KeyringFlags(fs)
+	resolveLayout := cli.LayoutFlags(fs)
+	decode := fs.Bool("decode", false, "print the components of every ID as a table")
+	asJSON := fs.Bool("json", false, "print the components of every ID as JSON lines")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	keyring, err := resolveKeyring()
+	if err != nil {
+		return cli.Config(err)
+	}
+	layout, err := resolveLayout()
+	if err != nil {
+		return cli.Configf("parsing layout: %w", err)
+	}
+	inputs, err := argsOrStdin(fs)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	decoded := make([]snowflake.Parts, 0, len(inputs))
+	for _, in := range inputs {
+		id, err := keyring.Reveal(in)
+		if err == nil {
+			var parts snowflake.Parts
+			parts, err = layout.Decode(id)
+			if err == nil {
+				decoded = append(decoded, parts)
+				continue
+			}
+		}
+		log.Printf("Error revealing '%s': %v", in, err)
+		failed++
+	}
+
+	switch {
+	case *asJSON:
+		err = printDecodedJSON(os.Stdout, layout, decoded)
+	case *decode:
+		err = printDecodedTable(os.Stdout, layout, decoded)
+	default:
+		for _, p := range decoded {
+			fmt.Println(p.ID)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("writing revealed IDs: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tokens could not be revealed", failed, len(inputs))
+	}
+	return nil
+}
+
+// argsOrStdin returns the positional arguments of fs or, if there are none,
+// the whitespace separated words on stdin.
+func argsOrStdin(fs *flag.FlagSet) ([]string, error) {
+	if fs.NArg() > 0 {
+		return fs.Args(), nil
+	}
+	words, err := readWords(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("reading stdin: %w", err)
+	}
+	return words, nil
+}
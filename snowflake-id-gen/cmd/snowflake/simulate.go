@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+	"github.com/abkolan/snowflake-id-gen/simulate"
+)
+
+// errSimulationFailed is returned when a simulation found duplicate or
+// out-of-order IDs, so that the exit status is 1.
+var errSimulationFailed = errors.New("simulation found duplicate or out-of-order IDs")
+
+// runSimulate implements the simulate subcommand: it runs a simulated fleet
+// of generators and checks that their IDs are unique.
+func runSimulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	resolveLayout := cli.LayoutFlags(fs)
+	nodes := fs.Int("nodes", simulate.DefaultNodes, "number of simulated generators")
+	machineIDs := fs.String("machine-ids", "leased", "how nodes get machine IDs: leased or mac")
+	spares := fs.Int("spares", 0, "spare machine IDs leased to every node (for -rollback switch)")
+	rollbackFlag := fs.String("rollback", "fail", "clock rollback strategy: fail, wait, borrow or switch")
+	rollbackTolerance := fs.Duration("rollback-tolerance", snowflake.DefaultRollbackTolerance, "largest clock regression absorbed by wait or borrow")
+	duration := fs.Duration("duration", simulate.DefaultDuration, "simulated time span")
+	step := fs.Duration("step", simulate.DefaultStep, "simulated time between rounds")
+	idsPerStep := fs.Int("ids-per-step", simulate.DefaultIDsPerStep, "IDs each node issues per round")
+	skew := fs.Duration("skew", 50*time.Millisecond, "largest initial clock offset of a node")
+	drift := fs.Float64("drift", 1e-4, "largest clock rate error of a node (1e-4 is 100 ppm)")
+	rollbackProbability := fs.Float64("rollback-probability", 0.001, "probability that a node's clock steps back in a round")
+	maxRollback := fs.Duration("max-rollback", 5*time.Millisecond, "largest backwards clock step")
+	seed := fs.Int64("seed", 1, "seed of the simulation")
+	maxCollisions := fs.Int("max-collisions", simulate.DefaultMaxCollisions, "collisions and regressions to report")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	verbose := fs.Bool("verbose", false, "keep the generators' log output, such as every detected clock rollback")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.Configf("unexpected arguments: %v", fs.Args())
+	}
+
+	layout, err := resolveLayout()
+	if err != nil {
+		return cli.Configf("parsing layout: %w", err)
+	}
+	scheme, err := simulate.ParseMachineIDScheme(*machineIDs)
+	if err != nil {
+		return cli.Config(err)
+	}
+	strategy, err := snowflake.ParseRollbackStrategy(*rollbackFlag)
+	if err != nil {
+		return cli.Config(err)
+	}
+
+	// Every injected rollback would otherwise log a line.
+	logOutput := log.Writer()
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	report, err := simulate.Run(simulate.Config{
+		Nodes:               *nodes,
+		Layout:              layout,
+		MachineIDs:          scheme,
+		SpareMachineIDs:     *spares,
+		Strategy:            strategy,
+		Tolerance:           *rollbackTolerance,
+		Duration:            *duration,
+		Step:                *step,
+		IDsPerStep:          *idsPerStep,
+		MaxSkew:             *skew,
+		MaxDrift:            *drift,
+		RollbackProbability: *rollbackProbability,
+		MaxRollback:         *maxRollback,
+		Seed:                *seed,
+		MaxCollisions:       *maxCollisions,
+	})
+	log.SetOutput(logOutput)
+	if err != nil {
+		return cli.Config(err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := printReport(report); err != nil {
+		return err
+	}
+	if !report.OK() {
+		return errSimulationFailed
+	}
+	return nil
+}
+
+// printReport prints a human readable summary of a simulation.
+func printReport(r simulate.Report) error {
+	injected, absorbed, failed := 0, 0, 0
+	for _, n := range r.Nodes {
+		injected += n.InjectedRollbacks
+		absorbed += int(n.Rollbacks.Waits + n.Rollbacks.Borrows + n.Rollbacks.Switches)
+		failed += int(n.Rollbacks.Failures)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "nodes\t%d (%s machine IDs, rollback %s)\n", len(r.Nodes), r.Config.MachineIDs, r.Config.Strategy)
+	fmt.Fprintf(tw, "simulated\t%v in steps of %v\n", r.Config.Duration, r.Config.Step)
+	fmt.Fprintf(tw, "IDs issued\t%d\n", r.IDs)
+	fmt.Fprintf(tw, "failed calls\t%d\n", r.Failures)
+	fmt.Fprintf(tw, "clock rollbacks\t%d injected, %d absorbed, %d failed\n", injected, absorbed, failed)
+	fmt.Fprintf(tw, "shared machine IDs\t%v\n", r.SharedMachineIDs)
+	fmt.Fprintf(tw, "collisions\t%d\n", r.CollisionCount)
+	fmt.Fprintf(tw, "regressions\t%d\n", r.RegressionCount)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Collisions) > 0 {
+		fmt.Println()
+		fmt.Println("Collisions:")
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTIME\tMACHINE\tSEQUENCE\tNODES\tCLOCKS")
+		for _, c := range r.Collisions {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d, %d\t%s, %s\n",
+				c.Parts.ID, c.Parts.Time.Format(time.RFC3339Nano), c.Parts.MachineID, c.Parts.Sequence,
+				c.First.Node, c.Second.Node,
+				c.First.ClockTime.Format(time.RFC3339Nano), c.Second.ClockTime.Format(time.RFC3339Nano))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(r.Regressions) > 0 {
+		fmt.Println()
+		fmt.Println("Regressions:")
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NODE\tPREVIOUS\tID\tTIME\tMACHINE\tSEQUENCE")
+		for _, g := range r.Regressions {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%d\t%d\n",
+				g.Issue.Node, g.Previous.ID, g.Parts.ID, g.Parts.Time.Format(time.RFC3339Nano), g.Parts.MachineID, g.Parts.Sequence)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+	"github.com/abkolan/snowflake-id-gen/registry"
+)
+
+// workerInfo is the JSON representation of a registered worker.
+type workerInfo struct {
+	registry.Worker
+	Live bool `json:"live"`
+}
+
+// runListWorkers implements the list-workers subcommand: it lists the
+// machine IDs registered in a registry directory and who holds them.
+func runListWorkers(args []string) error {
+	fs := flag.NewFlagSet("list-workers", flag.ContinueOnError)
+	dir := fs.String("registry-dir", "", "registry directory shared by the generators (required)")
+	liveOnly := fs.Bool("live", false, "omit stale registrations")
+	asJSON := fs.Bool("json", false, "print a JSON array instead of a table")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.Configf("unexpected arguments: %v", fs.Args())
+	}
+	if *dir == "" {
+		return cli.Configf("-registry-dir is required")
+	}
+
+	workers, err := registry.List(*dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	infos := make([]workerInfo, 0, len(workers))
+	for _, w := range workers {
+		if *liveOnly && !w.Live(now) {
+			continue
+		}
+		infos = append(infos, workerInfo{Worker: w, Live: w.Live(now)})
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(infos)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MACHINE\tHOSTNAME\tPID\tREGISTERED\tEXPIRES\tSTATUS")
+	for _, w := range infos {
+		status := "live"
+		if !w.Live {
+			status = "stale"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\n",
+			w.MachineID, w.Hostname, w.PID, w.RegisteredAt.Format(time.RFC3339), w.ExpiresAt.Format(time.RFC3339), status)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,47 @@
+// Command snowflaked is a long-running daemon serving Snowflake IDs over HTTP.
+//
+// Usage:
+//
+//	snowflaked [-machine-id N] [-machine-id-provider P,...] [-lease-dsn DSN | -registry-dir D] [-port P] [-resp-addr A] [-layout L] [-epoch T] [-rollback S]
+//
+// See package server for the endpoints; GET /metrics additionally serves
+// generator metrics in the Prometheus text format (see package metrics).
+// With -resp-addr the daemon also serves IDs over the Redis protocol (see
+// server.RESPServer). On SIGINT or SIGTERM the daemon stops accepting
+// connections and waits for in-flight requests to finish.
+//
+// The machine ID comes from the chain of providers selected with
+// -machine-id-provider (explicit -machine-id, $SNOWFLAKE_MACHINE_ID, then the
+// MAC address by default). With -lease-dsn it is instead leased from a MySQL
+// table (see package lease). With -registry-dir the resolved machine ID is
+// registered in a directory shared by the fleet (see package registry), and
+// the daemon refuses to start if a live peer holds it, or picks a free ID
+// with -registry-conflict reassign.
+//
+// With -checkpoint-file the generator persists its last issued timestamp so
+// that a restart with the clock behind does not reissue IDs. The
+// daemon stops issuing IDs if the lease is lost and releases it on shutdown.
+//
+// The daemon exits with status 2 on invalid flags or configuration and 1 if
+// serving fails.
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+	"github.com/abkolan/snowflake-id-gen/internal/daemon"
+)
+
+// main is the entry point of the program.
+func main() {
+	if err := daemon.Run("snowflaked", os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			log.Printf("Error: %v", err)
+		}
+		os.Exit(cli.ExitCode(err))
+	}
+}
@@ -0,0 +1,61 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidID is returned when an ID cannot have been produced by a generator.
+var ErrInvalidID = errors.New("invalid ID")
+
+// Parts holds the components of a decoded ID.
+type Parts struct {
+	// The decoded ID.
+	ID int64 `json:"id"`
+	// The wall-clock time the ID was generated at (start of its tick).
+	Time time.Time `json:"time"`
+	// The raw timestamp component (ticks since the layout's epoch).
+	Timestamp int64 `json:"timestamp"`
+	// The combined machine ID, as passed to NewSnowflake.
+	MachineID int64 `json:"machine_id"`
+	// The datacenter ID (always 0 if the layout has no datacenter bits).
+	DatacenterID int64 `json:"datacenter_id"`
+	// The worker ID (equal to MachineID if the layout has no datacenter bits).
+	WorkerID int64 `json:"worker_id"`
+	// The sequence number within the tick.
+	Sequence int64 `json:"sequence"`
+}
+
+// Decode splits an ID generated with DefaultLayout into its components.
+func Decode(id int64) (Parts, error) {
+	return DefaultLayout.Decode(id)
+}
+
+// Decode splits an ID generated by this generator into its components.
+func (s *Snowflake) Decode(id int64) (Parts, error) {
+	return s.layout.Decode(id)
+}
+
+// Decode splits an ID generated with this layout into its components.
+func (l Layout) Decode(id int64) (Parts, error) {
+	// IDs never have the sign bit set.
+	if id < 0 {
+		return Parts{}, fmt.Errorf("%w: %d is negative", ErrInvalidID, id)
+	}
+
+	// Shift and mask each component out of the ID.
+	sequence := id & l.MaxSequence()
+	machineID := (id >> l.SequenceBits) & l.MaxMachineID()
+	timestamp := id >> (l.MachineIDBits() + l.SequenceBits)
+
+	return Parts{
+		ID:           id,
+		Time:         l.Time(timestamp),
+		Timestamp:    timestamp,
+		MachineID:    machineID,
+		DatacenterID: machineID >> l.WorkerBits,
+		WorkerID:     machineID & l.MaxWorkerID(),
+		Sequence:     sequence,
+	}, nil
+}
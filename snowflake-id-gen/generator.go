@@ -0,0 +1,73 @@
+package snowflake
+
+import "context"
+
+// IDGenerator is the interface shared by the ID schemes of this module:
+// Snowflake, AtomicSnowflake and Pool, and the ULID and UUIDv7 generators of
+// packages ulid and uuidv7. Services that only need opaque, time-ordered IDs
+// can depend on it and select the scheme through configuration (see package
+// idgen).
+//
+// All implementations read time through a Clock and issue strictly
+// increasing IDs per generator, waiting for the clock when a tick's space
+// is used up.
+type IDGenerator interface {
+	// Scheme returns the name of the ID scheme: "snowflake", "ulid" or "uuidv7".
+	Scheme() string
+	// GenerateString returns a new ID in the scheme's canonical text form.
+	// It gives up with ctx's error (wrapped) once ctx is done while waiting
+	// for the clock.
+	GenerateString(ctx context.Context) (string, error)
+}
+
+// SchemeSnowflake is the scheme name of Snowflake IDs.
+const SchemeSnowflake = "snowflake"
+
+// Scheme returns SchemeSnowflake.
+func (s *Snowflake) Scheme() string {
+	return SchemeSnowflake
+}
+
+// GenerateString returns a new ID in decimal form.
+func (s *Snowflake) GenerateString(ctx context.Context) (string, error) {
+	id, err := s.GenerateIDContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ID(id).String(), nil
+}
+
+// Scheme returns SchemeSnowflake.
+func (s *AtomicSnowflake) Scheme() string {
+	return SchemeSnowflake
+}
+
+// GenerateString returns a new ID in decimal form.
+func (s *AtomicSnowflake) GenerateString(ctx context.Context) (string, error) {
+	id, err := s.GenerateIDContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ID(id).String(), nil
+}
+
+// Scheme returns SchemeSnowflake.
+func (p *Pool) Scheme() string {
+	return SchemeSnowflake
+}
+
+// GenerateString returns a new ID in decimal form.
+func (p *Pool) GenerateString(ctx context.Context) (string, error) {
+	id, err := p.GenerateIDContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ID(id).String(), nil
+}
+
+// Ensure the generators implement IDGenerator.
+var (
+	_ IDGenerator = (*Snowflake)(nil)
+	_ IDGenerator = (*AtomicSnowflake)(nil)
+	_ IDGenerator = (*Pool)(nil)
+)
@@ -1,3 +1,7 @@
 module github.com/abkolan/snowflake-id-gen
 
 go 1.24.0
+
+require github.com/go-sql-driver/mysql v1.9.0
+
+require filippo.io/edwards25519 v1.1.0 // indirect
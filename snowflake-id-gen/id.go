@@ -0,0 +1,214 @@
+package snowflake
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Alphabets of the string encodings. Each is in ascending ASCII order, so
+// the fixed-width encodings of non-negative IDs sort like the IDs themselves.
+const (
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// Fixed widths of the string encodings, enough for any 64-bit value.
+const (
+	base62Width = 11
+	base32Width = 13
+	hexWidth    = 16
+)
+
+// base62Index and crockfordIndex map characters back to digit values (-1 if invalid).
+var (
+	base62Index    = alphabetIndex(base62Alphabet)
+	crockfordIndex = crockfordDecodeTable()
+)
+
+// ID is a Snowflake ID with string encodings. IDs are always non-negative;
+// for those, the fixed-width encodings (Base62, Base32 and Hex) preserve
+// sort order, so encoded IDs can be compared as strings.
+//
+// ID marshals to JSON as a decimal string, because JavaScript numbers cannot
+// represent 64-bit integers exactly; it unmarshals from a string or a number.
+type ID int64
+
+// NextID is like GenerateID but returns an ID.
+func (s *Snowflake) NextID() (ID, error) {
+	id, err := s.GenerateID()
+	return ID(id), err
+}
+
+// Int64 returns the ID as an int64.
+func (id ID) Int64() int64 {
+	return int64(id)
+}
+
+// String returns the decimal representation of the ID.
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// Base62 returns the 11 character base62 encoding of the ID (0-9, A-Z, a-z).
+func (id ID) Base62() string {
+	return encodeFixed(uint64(id), base62Alphabet, base62Width)
+}
+
+// Base32 returns the 13 character Crockford base32 encoding of the ID.
+func (id ID) Base32() string {
+	return encodeFixed(uint64(id), crockfordAlphabet, base32Width)
+}
+
+// Hex returns the 16 character lowercase hexadecimal encoding of the ID.
+func (id ID) Hex() string {
+	return fmt.Sprintf("%0*x", hexWidth, uint64(id))
+}
+
+// ParseID parses the decimal representation of an ID.
+func ParseID(s string) (ID, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q is not a decimal ID", ErrInvalidID, s)
+	}
+	return ID(n), nil
+}
+
+// ParseBase62 parses an ID encoded with ID.Base62. Leading zeros may be omitted.
+func ParseBase62(s string) (ID, error) {
+	return decode(s, 62, func(c byte) int { return int(base62Index[c]) }, "base62")
+}
+
+// ParseBase32 parses an ID encoded with ID.Base32. Following Crockford's
+// rules it is case-insensitive, reads I and L as 1 and O as 0, and ignores
+// hyphens. Leading zeros may be omitted.
+func ParseBase32(s string) (ID, error) {
+	return decode(strings.ReplaceAll(s, "-", ""), 32, func(c byte) int { return int(crockfordIndex[c]) }, "base32")
+}
+
+// ParseHex parses an ID encoded with ID.Hex. Leading zeros may be omitted.
+func ParseHex(s string) (ID, error) {
+	n, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a hex ID", ErrInvalidID, s)
+	}
+	return checkRange(n, s, "hex")
+}
+
+// MarshalJSON encodes the ID as a decimal JSON string.
+func (id ID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + id.String() + `"`), nil
+}
+
+// UnmarshalJSON decodes an ID from a decimal JSON string or a JSON number.
+// JSON null leaves the ID unchanged.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	parsed, err := ParseID(s)
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// MarshalText encodes the ID in decimal.
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes an ID from decimal.
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// encodeFixed encodes n in the base of alphabet, left padded with the zero
+// digit to width characters.
+func encodeFixed(n uint64, alphabet string, width int) string {
+	base := uint64(len(alphabet))
+	buf := make([]byte, width)
+	for i := width - 1; i >= 0; i-- {
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf)
+}
+
+// decode parses s as a number in the given base, using digit to map
+// characters to values (-1 for invalid characters), rejecting overflow.
+func decode(s string, base uint64, digit func(byte) int, name string) (ID, error) {
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty %s ID", ErrInvalidID, name)
+	}
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		d := digit(s[i])
+		if d < 0 {
+			return 0, fmt.Errorf("%w: invalid %s character %q in %q", ErrInvalidID, name, s[i], s)
+		}
+		// Reject values that do not fit in 64 bits.
+		if n > (^uint64(0)-uint64(d))/base {
+			return 0, fmt.Errorf("%w: %s ID %q overflows 64 bits", ErrInvalidID, name, s)
+		}
+		n = n*base + uint64(d)
+	}
+	return checkRange(n, s, name)
+}
+
+// checkRange converts a decoded value to an ID, rejecting values with the
+// sign bit set since generators never produce them.
+func checkRange(n uint64, s, name string) (ID, error) {
+	if n > math.MaxInt64 {
+		return 0, fmt.Errorf("%w: %s ID %q exceeds the largest ID", ErrInvalidID, name, s)
+	}
+	return ID(n), nil
+}
+
+// alphabetIndex returns a table mapping each character of alphabet to its
+// position and every other byte to -1.
+func alphabetIndex(alphabet string) [256]int8 {
+	var index [256]int8
+	for i := range index {
+		index[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		index[alphabet[i]] = int8(i)
+	}
+	return index
+}
+
+// crockfordDecodeTable returns the Crockford base32 decoding table, which
+// also accepts lowercase letters and the look-alikes I, L and O.
+func crockfordDecodeTable() [256]int8 {
+	index := alphabetIndex(crockfordAlphabet)
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		c := crockfordAlphabet[i]
+		if c >= 'A' && c <= 'Z' {
+			index[c+'a'-'A'] = int8(i)
+		}
+	}
+	for _, c := range "IiLl" {
+		index[c] = 1
+	}
+	for _, c := range "Oo" {
+		index[c] = 0
+	}
+	return index
+}
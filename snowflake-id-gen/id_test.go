@@ -0,0 +1,170 @@
+package snowflake
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+// idEncodings are the fixed-width encodings that must round-trip and sort
+// like the IDs they encode.
+var idEncodings = []struct {
+	name   string
+	format func(ID) string
+	parse  func(string) (ID, error)
+}{
+	{"base62", ID.Base62, ParseBase62},
+	{"base32", ID.Base32, ParseBase32},
+	{"hex", ID.Hex, ParseHex},
+}
+
+// encodingTestIDs are in ascending order and cover the digit boundaries of
+// the encodings.
+var encodingTestIDs = []ID{0, 1, 31, 32, 61, 62, 63, 1 << 40, math.MaxInt64 - 1, math.MaxInt64}
+
+func TestIDEncodingsRoundTrip(t *testing.T) {
+	for _, enc := range idEncodings {
+		for _, id := range encodingTestIDs {
+			s := enc.format(id)
+			got, err := enc.parse(s)
+			if err != nil {
+				t.Errorf("%s: parsing %q (ID %d): %v", enc.name, s, id, err)
+				continue
+			}
+			if got != id {
+				t.Errorf("%s: %d encodes to %q, which parses back to %d", enc.name, id, s, got)
+			}
+		}
+	}
+}
+
+func TestIDEncodingsSortLikeIDs(t *testing.T) {
+	for _, enc := range idEncodings {
+		for i := 1; i < len(encodingTestIDs); i++ {
+			a, b := encodingTestIDs[i-1], encodingTestIDs[i]
+			if sa, sb := enc.format(a), enc.format(b); sa >= sb {
+				t.Errorf("%s: %d < %d but %q >= %q", enc.name, a, b, sa, sb)
+			}
+		}
+	}
+}
+
+func TestIDJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		ID ID `json:"id"`
+	}{math.MaxInt64})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if want := `{"id":"9223372036854775807"}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	tests := []struct {
+		json string
+		want ID
+	}{
+		{`"9223372036854775807"`, math.MaxInt64},
+		{`"0"`, 0},
+		{`42`, 42},
+		{`9223372036854775807`, math.MaxInt64},
+		// null leaves the ID unchanged.
+		{`null`, 5},
+	}
+	for _, tt := range tests {
+		id := ID(5)
+		if err := json.Unmarshal([]byte(tt.json), &id); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.json, err)
+			continue
+		}
+		if id != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.json, id, tt.want)
+		}
+	}
+
+	for _, in := range []string{`""`, `"abc"`, `"-1"`, `-1`, `1.5`, `"9223372036854775808"`, `true`, `"1"x`} {
+		var id ID
+		if err := json.Unmarshal([]byte(in), &id); err == nil {
+			t.Errorf("Unmarshal(%s) = %d, want an error", in, id)
+		}
+	}
+}
+
+func TestIDText(t *testing.T) {
+	text, err := ID(1234567890123).MarshalText()
+	if err != nil || string(text) != "1234567890123" {
+		t.Fatalf("MarshalText = %q, %v", text, err)
+	}
+	var id ID
+	if err := id.UnmarshalText(text); err != nil || id != 1234567890123 {
+		t.Errorf("UnmarshalText(%q) = %d, %v", text, id, err)
+	}
+	// ID works as a JSON map key through the text encoding.
+	var m map[ID]bool
+	if err := json.Unmarshal([]byte(`{"7":true}`), &m); err != nil || !m[7] {
+		t.Errorf("Unmarshal map key = %v, %v", m, err)
+	}
+	for _, in := range []string{"", "x", "-3", " 1", "9223372036854775808"} {
+		id := ID(9)
+		if err := id.UnmarshalText([]byte(in)); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("UnmarshalText(%q): got %v, want ErrInvalidID", in, err)
+		}
+		if id != 9 {
+			t.Errorf("UnmarshalText(%q) changed the ID to %d", in, id)
+		}
+	}
+}
+
+func TestParseBase32Crockford(t *testing.T) {
+	tests := []struct {
+		in   string
+		want ID
+	}{
+		{"1", 1},
+		{"i", 1},
+		{"L", 1},
+		{"o0O", 0},
+		{"zz", 32*32 - 1},
+		{"1-0-0", 32 * 32},
+		{"7ZZZZZZZZZZZZ", math.MaxInt64},
+	}
+	for _, tt := range tests {
+		got, err := ParseBase32(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseBase32(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) (ID, error)
+		in    string
+	}{
+		{"decimal empty", ParseID, ""},
+		{"decimal bad character", ParseID, "12a"},
+		{"decimal negative", ParseID, "-1"},
+		{"decimal overflow", ParseID, "9223372036854775808"},
+		{"base32 empty", ParseBase32, ""},
+		{"base32 only hyphens", ParseBase32, "--"},
+		// U is not part of Crockford's alphabet.
+		{"base32 bad character", ParseBase32, "1U"},
+		{"base32 sign bit", ParseBase32, "8000000000000"},
+		{"base32 overflow", ParseBase32, "ZZZZZZZZZZZZZZ"},
+		{"base62 empty", ParseBase62, ""},
+		{"base62 bad character", ParseBase62, "a-b"},
+		{"base62 overflow", ParseBase62, "zzzzzzzzzzzz"},
+		{"hex empty", ParseHex, ""},
+		{"hex bad character", ParseHex, "0g"},
+		{"hex sign bit", ParseHex, "8000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.parse(tt.in); !errors.Is(err, ErrInvalidID) {
+				t.Errorf("parsing %q = %d, %v; want ErrInvalidID", tt.in, got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+// Package idgen builds an ID generator of any scheme of this module from
+// configuration, so services can switch between Snowflake IDs, ULIDs and
+// UUIDv7s without code changes.
+package idgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/ulid"
+	"github.com/abkolan/snowflake-id-gen/uuidv7"
+)
+
+// Schemes lists the scheme names accepted by New.
+var Schemes = []string{snowflake.SchemeSnowflake, ulid.Scheme, uuidv7.Scheme}
+
+// Config selects and configures an ID generator.
+type Config struct {
+	// The ID scheme: "snowflake" (the default), "ulid" or "uuidv7".
+	Scheme string
+	// The machine ID of Snowflake generators; ignored by the other schemes.
+	MachineID int64
+	// Further options of Snowflake generators; ignored by the other schemes.
+	Options []snowflake.Option
+	// The time source of every scheme; nil means the system wall clock.
+	Clock snowflake.Clock
+}
+
+// New returns a generator for the configured scheme.
+func New(cfg Config) (snowflake.IDGenerator, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Scheme)) {
+	case "", snowflake.SchemeSnowflake:
+		opts := cfg.Options
+		if cfg.Clock != nil {
+			opts = append(opts[:len(opts):len(opts)], snowflake.WithClock(cfg.Clock))
+		}
+		gen, err := snowflake.NewSnowflake(cfg.MachineID, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return gen, nil
+	case ulid.Scheme:
+		var opts []ulid.Option
+		if cfg.Clock != nil {
+			opts = append(opts, ulid.WithClock(cfg.Clock))
+		}
+		return ulid.New(opts...), nil
+	case uuidv7.Scheme:
+		var opts []uuidv7.Option
+		if cfg.Clock != nil {
+			opts = append(opts, uuidv7.WithClock(cfg.Clock))
+		}
+		return uuidv7.New(opts...), nil
+	}
+	return nil, fmt.Errorf("unknown ID scheme %q (want %s)", cfg.Scheme, strings.Join(Schemes, ", "))
+}
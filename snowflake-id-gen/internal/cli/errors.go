@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+// Exit codes shared by the binaries in cmd.
+const (
+	// ExitFailure reports that generating, decoding or serving IDs failed.
+	ExitFailure = 1
+	// ExitConfig reports invalid flags, arguments or configuration.
+	ExitConfig = 2
+)
+
+// ConfigError marks an error caused by invalid flags, arguments or
+// configuration rather than by generating IDs.
+type ConfigError struct {
+	Err error
+}
+
+// Error returns the message of the wrapped error.
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Config marks err as a configuration error. It returns nil for a nil err.
+func Config(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ConfigError{Err: err}
+}
+
+// Configf formats a configuration error like fmt.Errorf.
+func Configf(format string, args ...any) error {
+	return Config(fmt.Errorf(format, args...))
+}
+
+// Parse parses args into fs, marking parse errors as configuration errors.
+// flag.ErrHelp is returned unchanged.
+func Parse(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return Config(err)
+}
+
+// ExitCode maps an error returned by a command to the process exit code:
+// 0 for nil and for flag.ErrHelp, ExitConfig for configuration errors and
+// ExitFailure for everything else.
+func ExitCode(err error) int {
+	var configErr *ConfigError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &configErr):
+		return ExitConfig
+	default:
+		return ExitFailure
+	}
+}
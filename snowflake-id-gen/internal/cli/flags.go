@@ -0,0 +1,90 @@
+// Package cli holds command-line helpers shared by the binaries in cmd.
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/obfuscate"
+)
+
+// LayoutFlags registers the -layout, -epoch and -tick flags on fs and returns
+// a function resolving them into a snowflake.Layout once fs has been parsed.
+func LayoutFlags(fs *flag.FlagSet) func() (snowflake.Layout, error) {
+	layoutFlag := fs.String("layout", "default", "bit layout: default, datacenter, wide-worker, sonyflake or ts/[dc/]worker/seq[@tick]")
+	epochFlag := fs.String("epoch", "", "custom epoch as an RFC 3339 timestamp (default 2024-01-01T00:00:00Z)")
+	tickFlag := fs.Duration("tick", 0, "timestamp resolution, e.g. 10ms (default: the layout's tick, 1ms unless stated)")
+
+	return func() (snowflake.Layout, error) {
+		// Resolve the layout first, then override its epoch and tick if requested.
+		layout, err := snowflake.ParseLayout(*layoutFlag)
+		if err != nil {
+			return snowflake.Layout{}, err
+		}
+		if *epochFlag != "" {
+			layout.Epoch, err = time.Parse(time.RFC3339, *epochFlag)
+			if err != nil {
+				return snowflake.Layout{}, fmt.Errorf("parsing epoch '%s': %w", *epochFlag, err)
+			}
+		}
+		if *tickFlag != 0 {
+			layout.Tick = *tickFlag
+			if err := layout.Validate(); err != nil {
+				return snowflake.Layout{}, err
+			}
+		}
+		return layout, nil
+	}
+}
+
+// MachineIDFlags registers the machine ID provider flags on fs and returns a
+// function resolving the machine ID for a layout once fs has been parsed.
+// The resolver also returns the name of the provider that won.
+func MachineIDFlags(fs *flag.FlagSet) func(snowflake.Layout) (int64, string, error) {
+	explicit := fs.Int64("machine-id", -1, "explicit machine ID (used by the explicit provider)")
+	providers := fs.String("machine-id-provider", "explicit,env,mac", "comma separated machine ID providers tried in order: explicit, env, ordinal, hash, ip, file, mac")
+	envVar := fs.String("machine-id-env", snowflake.DefaultMachineIDEnv, "environment variable read by the env provider")
+	file := fs.String("machine-id-file", "", "file read by the file provider")
+
+	return func(layout snowflake.Layout) (int64, string, error) {
+		chain, err := snowflake.ParseProviders(*providers,
+			snowflake.ExplicitProvider{ID: *explicit},
+			snowflake.EnvProvider{Var: *envVar},
+			snowflake.FileProvider{Path: *file},
+		)
+		if err != nil {
+			return 0, "", err
+		}
+		return chain.Resolve(layout)
+	}
+}
+
+// DefaultObfuscationKeysEnv is the environment variable KeyringFlags reads
+// keys from when no key file is given.
+const DefaultObfuscationKeysEnv = "SNOWFLAKE_OBFUSCATION_KEYS"
+
+// KeyringFlags registers the -key-file flag on fs and returns a function
+// loading the obfuscation keyring once fs has been parsed. Keys are read from
+// the file or, without one, from DefaultObfuscationKeysEnv; they are never
+// taken from the command line, where they would leak into shell history.
+func KeyringFlags(fs *flag.FlagSet) func() (*obfuscate.Keyring, error) {
+	keyFile := fs.String("key-file", "", "file with obfuscation keys, one version:hex-key per line (default $"+DefaultObfuscationKeysEnv+")")
+
+	return func() (*obfuscate.Keyring, error) {
+		if *keyFile != "" {
+			data, err := os.ReadFile(*keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading key file: %w", err)
+			}
+			return obfuscate.ParseKeyring(string(data))
+		}
+		spec, ok := os.LookupEnv(DefaultObfuscationKeysEnv)
+		if !ok {
+			return nil, fmt.Errorf("no obfuscation keys: set -key-file or $%s", DefaultObfuscationKeysEnv)
+		}
+		return obfuscate.ParseKeyring(spec)
+	}
+}
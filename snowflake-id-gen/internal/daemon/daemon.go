@@ -0,0 +1,304 @@
+// Package daemon runs the Snowflake ID service. It is shared by the
+// snowflaked binary and the serve subcommand of the snowflake binary.
+package daemon
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/cli"
+	"github.com/abkolan/snowflake-id-gen/lease"
+	"github.com/abkolan/snowflake-id-gen/metrics"
+	"github.com/abkolan/snowflake-id-gen/registry"
+	"github.com/abkolan/snowflake-id-gen/server"
+	_ "github.com/go-sql-driver/mysql" // Importing MySQL driver
+)
+
+// defaultLifetimeWarning is how long before the layout's timestamp is
+// exhausted the daemon starts warning, about five years.
+const defaultLifetimeWarning = 5 * 365 * 24 * time.Hour
+
+// Run parses the daemon flags from args and serves IDs over HTTP until
+// SIGINT or SIGTERM. Invalid flags and configuration are reported as
+// cli.ConfigError. name is used in usage messages.
+func Run(name string, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	resolveMachineID := cli.MachineIDFlags(fs)
+	port := fs.Int("port", 8080, "TCP port to listen on")
+	respAddr := fs.String("resp-addr", "", "also serve IDs over the Redis protocol on this address, e.g. :6380")
+	respIdleTimeout := fs.Duration("resp-idle-timeout", server.DefaultRESPIdleTimeout, "close RESP connections idle for this long")
+	resolveLayout := cli.LayoutFlags(fs)
+	rollbackFlag := fs.String("rollback", "fail", "clock rollback strategy: fail, wait, borrow or switch")
+	rollbackTolerance := fs.Duration("rollback-tolerance", snowflake.DefaultRollbackTolerance, "largest clock regression absorbed by wait or borrow")
+	spareMachineIDs := fs.String("spare-machine-ids", "", "comma separated machine IDs -rollback switch may switch to")
+	maxCount := fs.Int("max-count", server.DefaultMaxCount, "largest count accepted by GET /ids")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 10*time.Second, "how long to wait for in-flight requests on shutdown")
+	leaseDSN := fs.String("lease-dsn", "", "MySQL DSN of the machine ID lease database, e.g. root:pw@tcp(127.0.0.1:3306)/snowflake")
+	leaseTable := fs.String("lease-table", lease.DefaultTable, "machine ID lease table")
+	leaseTTL := fs.Duration("lease-ttl", lease.DefaultTTL, "how long a machine ID lease stays valid without a heartbeat")
+	registryDir := fs.String("registry-dir", "", "shared directory where generators register their machine IDs to detect collisions")
+	registryTTL := fs.Duration("registry-ttl", registry.DefaultTTL, "how long a machine ID registration stays live without a heartbeat")
+	registryConflict := fs.String("registry-conflict", "fail", "what to do when a live peer holds the machine ID: fail or reassign")
+	checkpointFile := fs.String("checkpoint-file", "", "file persisting the last issued timestamp across restarts")
+	checkpointInterval := fs.Duration("checkpoint-interval", snowflake.DefaultCheckpointInterval, "how far ahead of the clock checkpoints reserve timestamps")
+	lifetimeWarning := fs.Duration("lifetime-warning", defaultLifetimeWarning, "log a warning once less than this is left before the layout's timestamp is exhausted (0 to disable)")
+	if err := cli.Parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return cli.Configf("unexpected arguments: %v", fs.Args())
+	}
+
+	// Resolve the generator configuration.
+	layout, err := resolveLayout()
+	if err != nil {
+		return cli.Configf("parsing layout: %w", err)
+	}
+	rollback, err := snowflake.ParseRollbackStrategy(*rollbackFlag)
+	if err != nil {
+		return cli.Configf("parsing rollback strategy: %w", err)
+	}
+	spares, err := parseMachineIDs(*spareMachineIDs)
+	if err != nil {
+		return cli.Configf("parsing -spare-machine-ids: %w", err)
+	}
+	switch {
+	case rollback == snowflake.RollbackSwitchMachineID && len(spares) == 0:
+		return cli.Configf("-rollback switch needs -spare-machine-ids")
+	case rollback != snowflake.RollbackSwitchMachineID && len(spares) > 0:
+		return cli.Configf("-spare-machine-ids is only used with -rollback switch")
+	case len(spares) > 0 && (*leaseDSN != "" || *registryDir != ""):
+		// Switching to a spare would leave the leased or registered ID.
+		return cli.Configf("-spare-machine-ids cannot be combined with -lease-dsn or -registry-dir")
+	case len(spares) > 0 && *checkpointFile != "":
+		// The checkpoint does not record what the spares have issued.
+		return cli.Configf("-rollback switch cannot be combined with -checkpoint-file")
+	}
+	if *registryDir != "" && *leaseDSN != "" {
+		return cli.Configf("-registry-dir and -lease-dsn cannot be combined: leased machine IDs are already exclusive")
+	}
+	if *registryConflict != "fail" && *registryConflict != "reassign" {
+		return cli.Configf("invalid -registry-conflict %q: want fail or reassign", *registryConflict)
+	}
+	generatorMetrics := metrics.NewGenerator()
+	opts := []snowflake.Option{
+		snowflake.WithLayout(layout),
+		snowflake.WithRollbackStrategy(rollback),
+		snowflake.WithRollbackTolerance(*rollbackTolerance),
+		snowflake.WithSpareMachineIDs(spares...),
+		snowflake.WithObserver(generatorMetrics),
+	}
+	if *lifetimeWarning > 0 {
+		opts = append(opts, snowflake.WithLifetimeWarning(*lifetimeWarning, nil))
+	}
+	if *checkpointFile != "" {
+		opts = append(opts, snowflake.WithCheckpoint(snowflake.FileCheckpoint{Path: *checkpointFile}, *checkpointInterval))
+	}
+
+	// Determine the machine ID: leased or from the provider chain, checked
+	// against the registry if there is one.
+	var machineID int64
+	var machineLease *lease.Lease
+	var leaseDB *sql.DB
+	var registration *registry.Registration
+	if *leaseDSN != "" {
+		machineLease, leaseDB, err = acquireLease(*leaseDSN, *leaseTable, *leaseTTL, layout)
+		if err != nil {
+			return fmt.Errorf("leasing machine ID: %w", err)
+		}
+		machineID = machineLease.MachineID()
+		opts = append(opts, snowflake.WithLease(machineLease))
+	} else {
+		machineID, _, err = resolveMachineID(layout)
+		if err != nil {
+			return cli.Configf("deriving machine ID: %w", err)
+		}
+		if *registryDir != "" {
+			registration, err = register(*registryDir, *registryTTL, *registryConflict == "reassign", machineID, layout)
+			if err != nil {
+				return fmt.Errorf("registering machine ID: %w", err)
+			}
+			machineID = registration.MachineID()
+			opts = append(opts, snowflake.WithLease(registration))
+		}
+	}
+	// Give up the lease or registration again if the generator cannot be
+	// started; on shutdown they are released explicitly.
+	defer func() {
+		if machineLease != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+			if err := machineLease.Release(ctx); err != nil {
+				log.Printf("Error releasing machine ID lease: %v", err)
+			}
+			cancel()
+		}
+		if leaseDB != nil {
+			leaseDB.Close()
+		}
+		if registration != nil {
+			if err := registration.Release(context.Background()); err != nil {
+				log.Printf("Error releasing machine ID registration: %v", err)
+			}
+		}
+	}()
+
+	generator, err := snowflake.NewSnowflake(machineID, opts...)
+	if err != nil {
+		// A checkpoint that cannot be read is a runtime failure; anything
+		// else NewSnowflake rejects is configuration.
+		if errors.Is(err, snowflake.ErrCheckpoint) {
+			return fmt.Errorf("creating Snowflake generator: %w", err)
+		}
+		return cli.Configf("creating Snowflake generator: %w", err)
+	}
+	// A layout that has run out of timestamps cannot issue a single ID.
+	if generator.Remaining() <= 0 {
+		return cli.Configf("layout %s: %w", layout, snowflake.ErrEpochExhausted)
+	}
+	log.Printf("Snowflake generator created with Machine ID: %d, layout %s", machineID, layout)
+	generatorMetrics.Lag = generator.Lag
+	generatorMetrics.Remaining = generator.Remaining
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", generatorMetrics)
+	mux.Handle("/", server.NewHTTPHandler(generator, *maxCount))
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", *port),
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	// Serve until the listener fails or a shutdown signal arrives.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 2)
+	go func() {
+		log.Printf("Listening on %s", srv.Addr)
+		serveErr <- fmt.Errorf("serving HTTP: %w", srv.ListenAndServe())
+	}()
+	var respSrv *server.RESPServer
+	if *respAddr != "" {
+		respSrv = server.NewRESPServer(generator, *maxCount, *respIdleTimeout)
+		go func() {
+			log.Printf("Serving RESP on %s", *respAddr)
+			serveErr <- fmt.Errorf("serving RESP: %w", respSrv.ListenAndServe(*respAddr))
+		}()
+	}
+
+	var runErr error
+	select {
+	case err := <-serveErr:
+		runErr = err
+	case <-ctx.Done():
+		// Let in-flight requests finish before exiting.
+		log.Println("Shutting down...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		runErr = errors.Join(runErr, fmt.Errorf("shutting down: %w", err))
+	}
+	if respSrv != nil {
+		if err := respSrv.Shutdown(shutdownCtx); err != nil {
+			runErr = errors.Join(runErr, fmt.Errorf("shutting down RESP: %w", err))
+		}
+	}
+	if err := generator.Close(); err != nil {
+		log.Printf("Error saving checkpoint: %v", err)
+	}
+	if machineLease != nil {
+		if err := machineLease.Release(shutdownCtx); err != nil {
+			log.Printf("Error releasing machine ID lease: %v", err)
+		}
+		machineLease = nil
+	}
+	if registration != nil {
+		if err := registration.Release(shutdownCtx); err != nil {
+			log.Printf("Error releasing machine ID registration: %v", err)
+		}
+		registration = nil
+	}
+	if runErr == nil {
+		log.Println("Shutdown complete.")
+	}
+	return runErr
+}
+
+// parseMachineIDs parses a comma separated list of machine IDs; an empty
+// string is an empty list. Ranges are checked by the generator.
+func parseMachineIDs(s string) ([]int64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// register records machineID in the registry directory. If a live peer
+// holds it, register fails or, with reassign, registers the lowest free
+// machine ID instead.
+func register(dir string, ttl time.Duration, reassign bool, machineID int64, layout snowflake.Layout) (*registry.Registration, error) {
+	reg, err := registry.NewDir(registry.Config{Dir: dir, TTL: ttl, Layout: layout})
+	if err != nil {
+		return nil, err
+	}
+	if reassign {
+		return reg.RegisterAny(machineID)
+	}
+	return reg.Register(machineID)
+}
+
+// acquireLease connects to the lease database, creates the lease table if
+// needed and leases a free machine ID of the layout. The caller closes the
+// returned database after releasing the lease.
+func acquireLease(dsn, table string, ttl time.Duration, layout snowflake.Layout) (*lease.Lease, *sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, nil, cli.Config(err)
+	}
+	l, err := leaseFrom(db, table, ttl, layout)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return l, db, nil
+}
+
+// leaseFrom leases a free machine ID from the lease table in db.
+func leaseFrom(db *sql.DB, table string, ttl time.Duration, layout snowflake.Layout) (*lease.Lease, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Ensure the connection is available.
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("lease database is not reachable: %w", err)
+	}
+
+	leaser, err := lease.NewMySQL(db, lease.Config{Table: table, TTL: ttl, Layout: layout})
+	if err != nil {
+		return nil, cli.Config(err)
+	}
+	if err := leaser.EnsureTable(ctx); err != nil {
+		return nil, err
+	}
+	return leaser.Acquire(ctx)
+}
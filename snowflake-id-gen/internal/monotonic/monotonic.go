@@ -0,0 +1,122 @@
+// Package monotonic implements the layout shared by ULIDs and UUIDv7s: a
+// Unix millisecond timestamp followed by random bits that are incremented,
+// instead of redrawn, within a millisecond so that IDs strictly increase.
+package monotonic
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// MaxBits is the largest number of random bits a Generator supports.
+const MaxBits = 80
+
+// maxMillis bounds the 48-bit timestamp of both formats.
+const maxMillis = 1<<48 - 1
+
+// Generator issues (timestamp, counter) pairs. Within one millisecond the
+// counter is incremented; in a new millisecond it is drawn from the entropy
+// source. If the clock moves backwards, the generator keeps incrementing the
+// counter of the last timestamp, as the ULID specification does for
+// monotonic generation. It is safe for concurrent use.
+type Generator struct {
+	// Mutex to protect concurrent access to the state.
+	mu sync.Mutex
+	// The time source.
+	clock snowflake.Clock
+	// Source of the random counter start values.
+	entropy io.Reader
+	// The counter is split into hi (bits above 64) and lo.
+	hiMask, loMask uint64
+	// The last issued timestamp (Unix milliseconds), -1 before the first.
+	lastMillis int64
+	// The last issued counter.
+	hi, lo uint64
+}
+
+// New returns a generator with a counter of the given number of bits
+// (1 to MaxBits).
+func New(clock snowflake.Clock, entropy io.Reader, bits uint) *Generator {
+	if bits == 0 || bits > MaxBits {
+		panic(fmt.Sprintf("monotonic: %d counter bits, want 1 to %d", bits, MaxBits))
+	}
+	g := &Generator{clock: clock, entropy: entropy, lastMillis: -1}
+	if bits >= 64 {
+		g.loMask = ^uint64(0)
+		g.hiMask = 1<<(bits-64) - 1
+	} else {
+		g.loMask = 1<<bits - 1
+	}
+	return g
+}
+
+// Next returns the next timestamp and counter. When the counter of the
+// current millisecond is exhausted it waits for the next millisecond, until
+// ctx is done, if wait is set and fails with snowflake.ErrSequenceExhausted
+// otherwise.
+func (g *Generator) Next(ctx context.Context, wait bool) (millis int64, hi, lo uint64, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now().UnixMilli()
+	if now < 0 || now > maxMillis {
+		return 0, 0, 0, fmt.Errorf("time %d ms is outside the 48-bit timestamp range", now)
+	}
+
+	// A new millisecond starts from a random counter.
+	if now > g.lastMillis {
+		return g.start(now)
+	}
+
+	// Same millisecond, or the clock moved backwards: increment the counter.
+	switch {
+	case g.lo < g.loMask:
+		g.lo++
+		return g.lastMillis, g.hi, g.lo, nil
+	case g.hi < g.hiMask:
+		g.hi++
+		g.lo = 0
+		return g.lastMillis, g.hi, g.lo, nil
+	}
+
+	// The counter is exhausted; move on to the next millisecond.
+	if !wait {
+		return 0, 0, 0, snowflake.ErrSequenceExhausted
+	}
+	now, err = g.tilNextMillis(ctx)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return g.start(now)
+}
+
+// start draws a random counter for the millisecond now and issues it.
+func (g *Generator) start(now int64) (int64, uint64, uint64, error) {
+	var buf [16]byte
+	if _, err := io.ReadFull(g.entropy, buf[:]); err != nil {
+		return 0, 0, 0, fmt.Errorf("reading entropy: %w", err)
+	}
+	g.lastMillis = now
+	g.hi = binary.BigEndian.Uint64(buf[:8]) & g.hiMask
+	g.lo = binary.BigEndian.Uint64(buf[8:]) & g.loMask
+	return g.lastMillis, g.hi, g.lo, nil
+}
+
+// tilNextMillis blocks until the clock is past the last issued millisecond
+// or ctx is done.
+func (g *Generator) tilNextMillis(ctx context.Context) (int64, error) {
+	now := g.clock.Now().UnixMilli()
+	for now <= g.lastMillis {
+		if err := snowflake.PollClock(ctx, g.clock, time.Duration(g.lastMillis+1-now)*time.Millisecond); err != nil {
+			return 0, fmt.Errorf("waiting for the next millisecond: %w", err)
+		}
+		now = g.clock.Now().UnixMilli()
+	}
+	return now, nil
+}
@@ -0,0 +1,132 @@
+package monotonic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// testStart is the time the tests start their clocks at.
+var testStart = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// zeroEntropy makes every millisecond start its counter at zero.
+type zeroEntropy struct{}
+
+func (zeroEntropy) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// stoppedClock always returns the same time and cannot sleep, so waiting
+// for the next millisecond only ends with the context.
+type stoppedClock struct{ now time.Time }
+
+func (c stoppedClock) Now() time.Time { return c.now }
+
+// next calls Next and fails the test on errors.
+func next(t *testing.T, g *Generator) (int64, uint64, uint64) {
+	t.Helper()
+	millis, hi, lo, err := g.Next(context.Background(), true)
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	return millis, hi, lo
+}
+
+func TestNextIncrementsWithinMillisecond(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	g := New(clock, zeroEntropy{}, 70)
+
+	for want := uint64(0); want < 100; want++ {
+		millis, hi, lo := next(t, g)
+		if millis != testStart.UnixMilli() || hi != 0 || lo != want {
+			t.Fatalf("Next = %d, %d, %d, want %d, 0, %d", millis, hi, lo, testStart.UnixMilli(), want)
+		}
+	}
+}
+
+func TestNextCarriesIntoHighBits(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	// The counter starts with every low bit set.
+	entropy := bytes.NewReader(append(make([]byte, 8), bytes.Repeat([]byte{0xff}, 8)...))
+	g := New(clock, entropy, 80)
+
+	if _, hi, lo := next(t, g); hi != 0 || lo != ^uint64(0) {
+		t.Fatalf("first counter = %d, %d, want 0 and all ones", hi, lo)
+	}
+	if _, hi, lo := next(t, g); hi != 1 || lo != 0 {
+		t.Errorf("counter after the carry = %d, %d, want 1 and 0", hi, lo)
+	}
+}
+
+func TestNextAfterClockRollback(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	g := New(clock, zeroEntropy{}, 70)
+	next(t, g)
+
+	// The counter of the last millisecond keeps increasing.
+	clock.Advance(-5 * time.Millisecond)
+	millis, _, lo := next(t, g)
+	if millis != testStart.UnixMilli() || lo != 1 {
+		t.Errorf("Next after a rollback = %d, %d, want %d, 1", millis, lo, testStart.UnixMilli())
+	}
+
+	// Once the clock has caught up, a new millisecond starts over.
+	clock.Advance(6 * time.Millisecond)
+	millis, _, lo = next(t, g)
+	if millis != testStart.UnixMilli()+1 || lo != 0 {
+		t.Errorf("Next after catching up = %d, %d, want %d, 0", millis, lo, testStart.UnixMilli()+1)
+	}
+}
+
+func TestNextCounterExhausted(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	// A 4-bit counter allows 16 values per millisecond.
+	g := New(clock, zeroEntropy{}, 4)
+	for range 16 {
+		next(t, g)
+	}
+	if _, _, _, err := g.Next(context.Background(), false); !errors.Is(err, snowflake.ErrSequenceExhausted) {
+		t.Fatalf("Next without waiting: got %v, want ErrSequenceExhausted", err)
+	}
+
+	// Waiting sleeps on the manual clock until the next millisecond.
+	millis, _, lo := next(t, g)
+	if millis != testStart.UnixMilli()+1 || lo != 0 {
+		t.Errorf("Next after exhaustion = %d, %d, want %d, 0", millis, lo, testStart.UnixMilli()+1)
+	}
+}
+
+func TestNextContextCancelled(t *testing.T) {
+	g := New(stoppedClock{testStart}, zeroEntropy{}, 1)
+	next(t, g)
+	next(t, g)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, _, err := g.Next(ctx, true); !errors.Is(err, context.Canceled) {
+		t.Errorf("Next with a cancelled context: got %v, want context.Canceled", err)
+	}
+}
+
+func TestNextOutOfRange(t *testing.T) {
+	g := New(stoppedClock{time.UnixMilli(-1)}, zeroEntropy{}, 8)
+	if _, _, _, err := g.Next(context.Background(), true); err == nil {
+		t.Error("Next before 1970 succeeded")
+	}
+	g = New(stoppedClock{time.UnixMilli(maxMillis + 1)}, zeroEntropy{}, 8)
+	if _, _, _, err := g.Next(context.Background(), true); err == nil {
+		t.Error("Next past the 48-bit range succeeded")
+	}
+}
+
+func TestNextEntropyError(t *testing.T) {
+	g := New(snowflake.NewManualClock(testStart), bytes.NewReader(nil), 8)
+	if _, _, _, err := g.Next(context.Background(), true); err == nil {
+		t.Error("Next with exhausted entropy succeeded")
+	}
+}
@@ -0,0 +1,314 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// totalBits is the number of usable bits in an ID. The 64th (sign) bit is
+// always zero so that IDs stay positive when stored as int64.
+const totalBits = 63
+
+// ErrInvalidLayout is returned when a Layout does not describe a usable ID format.
+var ErrInvalidLayout = errors.New("invalid layout")
+
+// Layout describes how the 63 usable bits of an ID are split between the
+// timestamp, the (optional) datacenter ID, the worker ID and the sequence
+// number, which epoch the timestamp is relative to and how long one tick of
+// the timestamp lasts.
+//
+// The machine ID accepted by NewSnowflake is the datacenter ID and worker ID
+// packed together, datacenter in the high bits. A layout without datacenter
+// bits simply uses the whole machine ID as the worker ID.
+type Layout struct {
+	// Number of bits allocated for the timestamp (in ticks).
+	TimestampBits uint8
+	// Number of bits allocated for the datacenter ID (may be 0).
+	DatacenterBits uint8
+	// Number of bits allocated for the worker ID.
+	WorkerBits uint8
+	// Number of bits allocated for the sequence number.
+	SequenceBits uint8
+	// The instant the timestamp component counts from.
+	Epoch time.Time
+	// The resolution of the timestamp. Zero means one millisecond.
+	Tick time.Duration
+}
+
+// DefaultEpoch is the custom epoch used by the predefined layouts
+// (January 1, 2024, 00:00:00 UTC). Using a recent epoch extends the lifespan
+// of the timestamp component.
+var DefaultEpoch = time.UnixMilli(epoch).UTC()
+
+// Predefined layouts for common use cases.
+var (
+	// DefaultLayout is the classic 41-bit timestamp, 10-bit machine ID and
+	// 12-bit sequence split.
+	DefaultLayout = Layout{TimestampBits: 41, WorkerBits: machineIDBits, SequenceBits: sequenceBits, Epoch: DefaultEpoch}
+	// DatacenterLayout splits the 10 machine bits into a 5-bit datacenter ID
+	// and a 5-bit worker ID, as in the original Twitter implementation.
+	DatacenterLayout = Layout{TimestampBits: 41, DatacenterBits: 5, WorkerBits: 5, SequenceBits: 12, Epoch: DefaultEpoch}
+	// WideWorkerLayout trades sequence space for machine IDs: 14-bit worker
+	// IDs with an 8-bit sequence (256 IDs per millisecond per worker).
+	WideWorkerLayout = Layout{TimestampBits: 41, WorkerBits: 14, SequenceBits: 8, Epoch: DefaultEpoch}
+	// SonyflakeLayout follows Sonyflake: 10 ms ticks in 39 bits (~174
+	// years), 16-bit machine IDs and an 8-bit sequence (25,600 IDs per
+	// second per machine).
+	SonyflakeLayout = Layout{TimestampBits: 39, WorkerBits: 16, SequenceBits: 8, Epoch: DefaultEpoch, Tick: 10 * time.Millisecond}
+)
+
+// namedLayouts maps the names accepted by ParseLayout to predefined layouts.
+var namedLayouts = map[string]Layout{
+	"default":     DefaultLayout,
+	"datacenter":  DatacenterLayout,
+	"wide-worker": WideWorkerLayout,
+	"sonyflake":   SonyflakeLayout,
+}
+
+// ParseLayout parses a layout from either the name of a predefined layout
+// ("default", "datacenter", "wide-worker", "sonyflake") or a bit
+// specification of the form "timestamp/worker/sequence" or
+// "timestamp/datacenter/worker/sequence", e.g. "41/5/5/12". A bit
+// specification may end in "@tick" to select the tick duration, e.g.
+// "39/16/8@10ms". Parsed layouts use DefaultEpoch.
+func ParseLayout(s string) (Layout, error) {
+	// Look up predefined layouts first.
+	if l, ok := namedLayouts[strings.ToLower(strings.TrimSpace(s))]; ok {
+		return l, nil
+	}
+
+	// Split off the optional tick duration.
+	spec, tickSpec, hasTick := strings.Cut(s, "@")
+	var tick time.Duration
+	if hasTick {
+		var err error
+		tick, err = time.ParseDuration(strings.TrimSpace(tickSpec))
+		if err != nil {
+			return Layout{}, fmt.Errorf("%w: bad tick %q in %q", ErrInvalidLayout, tickSpec, s)
+		}
+	}
+
+	// Parse the slash separated bit counts.
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 && len(parts) != 4 {
+		return Layout{}, fmt.Errorf("%w: %q is neither a known layout nor of the form ts/[dc/]worker/seq", ErrInvalidLayout, s)
+	}
+	bits := make([]uint8, len(parts))
+	for i, p := range parts {
+		n, err := strconv.ParseUint(strings.TrimSpace(p), 10, 8)
+		if err != nil {
+			return Layout{}, fmt.Errorf("%w: bad bit count %q in %q", ErrInvalidLayout, p, s)
+		}
+		bits[i] = uint8(n)
+	}
+
+	l := Layout{TimestampBits: bits[0], SequenceBits: bits[len(bits)-1], Epoch: DefaultEpoch, Tick: tick}
+	if len(bits) == 4 {
+		l.DatacenterBits, l.WorkerBits = bits[1], bits[2]
+	} else {
+		l.WorkerBits = bits[1]
+	}
+	// Reject layouts that cannot be used right away.
+	if err := l.Validate(); err != nil {
+		return Layout{}, err
+	}
+	return l, nil
+}
+
+// Validate checks that the bits add up to 63, that the timestamp, worker and
+// sequence components are not empty, that an epoch is set and that the tick
+// is not negative.
+func (l Layout) Validate() error {
+	// Sum in int to avoid uint8 overflow on silly inputs.
+	sum := int(l.TimestampBits) + int(l.DatacenterBits) + int(l.WorkerBits) + int(l.SequenceBits)
+	if sum != totalBits {
+		return fmt.Errorf("%w: %s uses %d bits, want %d", ErrInvalidLayout, l.BitString(), sum, totalBits)
+	}
+	if l.TimestampBits == 0 || l.WorkerBits == 0 || l.SequenceBits == 0 {
+		return fmt.Errorf("%w: %s must have at least one timestamp, worker and sequence bit", ErrInvalidLayout, l.BitString())
+	}
+	if l.Epoch.IsZero() {
+		return fmt.Errorf("%w: epoch is not set", ErrInvalidLayout)
+	}
+	if l.Tick < 0 {
+		return fmt.Errorf("%w: tick %v is negative", ErrInvalidLayout, l.Tick)
+	}
+	return nil
+}
+
+// TickDuration returns the resolution of the timestamp component.
+func (l Layout) TickDuration() time.Duration {
+	if l.Tick == 0 {
+		return time.Millisecond
+	}
+	return l.Tick
+}
+
+// Timestamp converts t into the timestamp component of the layout: the
+// number of whole ticks since the epoch.
+func (l Layout) Timestamp(t time.Time) int64 {
+	return newTimeBase(l).ticks(t)
+}
+
+// Time converts a timestamp component back into the start of its tick.
+func (l Layout) Time(timestamp int64) time.Time {
+	return newTimeBase(l).time(timestamp)
+}
+
+// MachineIDBits returns the number of bits used by the combined machine ID.
+func (l Layout) MachineIDBits() uint8 {
+	return l.DatacenterBits + l.WorkerBits
+}
+
+// MaxMachineID returns the largest combined machine ID the layout can hold.
+func (l Layout) MaxMachineID() int64 {
+	return maxValue(l.MachineIDBits())
+}
+
+// MaxDatacenterID returns the largest datacenter ID (0 if the layout has none).
+func (l Layout) MaxDatacenterID() int64 {
+	return maxValue(l.DatacenterBits)
+}
+
+// MaxWorkerID returns the largest worker ID.
+func (l Layout) MaxWorkerID() int64 {
+	return maxValue(l.WorkerBits)
+}
+
+// MaxSequence returns the largest sequence number within one tick.
+func (l Layout) MaxSequence() int64 {
+	return maxValue(l.SequenceBits)
+}
+
+// MaxTimestamp returns the largest timestamp (ticks since the epoch) that
+// fits in the layout.
+func (l Layout) MaxTimestamp() int64 {
+	return maxValue(l.TimestampBits)
+}
+
+// MachineID packs a datacenter ID and a worker ID into a combined machine ID.
+func (l Layout) MachineID(datacenterID, workerID int64) (int64, error) {
+	// Validate both components against their own bit widths.
+	if datacenterID < 0 || datacenterID > l.MaxDatacenterID() {
+		return 0, fmt.Errorf("%w: datacenter ID %d is not between 0 and %d", ErrInvalidMachineID, datacenterID, l.MaxDatacenterID())
+	}
+	if workerID < 0 || workerID > l.MaxWorkerID() {
+		return 0, fmt.Errorf("%w: worker ID %d is not between 0 and %d", ErrInvalidMachineID, workerID, l.MaxWorkerID())
+	}
+	return datacenterID<<l.WorkerBits | workerID, nil
+}
+
+// IDsPerTick returns how many IDs a single generator can issue per tick
+// before it has to wait for the clock.
+func (l Layout) IDsPerTick() int64 {
+	return l.MaxSequence() + 1
+}
+
+// IDsPerMillisecond returns how many IDs a single generator can issue per
+// millisecond, rounded down. It is 0 for coarse ticks with few sequence
+// bits; see IDsPerSecond.
+func (l Layout) IDsPerMillisecond() int64 {
+	return int64(l.IDsPerSecond() / 1000)
+}
+
+// IDsPerSecond returns how many IDs a single generator can issue per second.
+func (l Layout) IDsPerSecond() float64 {
+	return float64(l.IDsPerTick()) * float64(time.Second) / float64(l.TickDuration())
+}
+
+// Lifespan returns how long after the epoch the timestamp component
+// overflows. It saturates at the largest representable time.Duration.
+func (l Layout) Lifespan() time.Duration {
+	// Number of ticks representable by the timestamp bits.
+	ticks := l.MaxTimestamp() + 1
+	tick := int64(l.TickDuration())
+	if ticks > math.MaxInt64/tick {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(ticks * tick)
+}
+
+// ExhaustionTime returns the start of the first tick whose timestamp no
+// longer fits into the timestamp bits. From then on generators fail with
+// ErrEpochExhausted. Like Lifespan it saturates, about 292 years after the
+// epoch.
+func (l Layout) ExhaustionTime() time.Time {
+	return l.Epoch.Add(l.Lifespan()).UTC()
+}
+
+// Remaining returns how long IDs can still be issued after now before the
+// timestamp is exhausted. It is negative once the layout is exhausted.
+func (l Layout) Remaining(now time.Time) time.Duration {
+	return l.ExhaustionTime().Sub(now)
+}
+
+// String returns a human readable description of the layout.
+func (l Layout) String() string {
+	s := fmt.Sprintf("%s epoch=%s", l.BitString(), l.Epoch.UTC().Format(time.RFC3339))
+	if l.TickDuration() != time.Millisecond {
+		s += " tick=" + l.TickDuration().String()
+	}
+	return s
+}
+
+// BitString returns the bit split as "ts/dc/worker/seq", a form ParseLayout
+// accepts.
+func (l Layout) BitString() string {
+	return fmt.Sprintf("%d/%d/%d/%d", l.TimestampBits, l.DatacenterBits, l.WorkerBits, l.SequenceBits)
+}
+
+// timeBase converts between times and timestamp components in nanosecond
+// arithmetic, caching what a generator needs on every call.
+type timeBase struct {
+	// The epoch in Unix nanoseconds.
+	epochNanos int64
+	// The tick duration in nanoseconds.
+	tickNanos int64
+}
+
+// newTimeBase returns the timeBase of a layout.
+func newTimeBase(l Layout) timeBase {
+	return timeBase{epochNanos: l.Epoch.UnixNano(), tickNanos: int64(l.TickDuration())}
+}
+
+// ticks returns the number of whole ticks from the epoch to t, rounding
+// towards negative infinity for times before the epoch.
+func (b timeBase) ticks(t time.Time) int64 {
+	d := t.UnixNano() - b.epochNanos
+	q := d / b.tickNanos
+	if d%b.tickNanos < 0 {
+		q--
+	}
+	return q
+}
+
+// time returns the start of the given tick. It works in seconds and
+// nanoseconds so that decoding far-future timestamps does not overflow.
+func (b timeBase) time(ts int64) time.Time {
+	const nanosPerSecond = int64(time.Second)
+	// ts*tick = ts*wholeSeconds*1e9 + (hi*1e9+lo)*fraction
+	wholeSeconds, fraction := b.tickNanos/nanosPerSecond, b.tickNanos%nanosPerSecond
+	hi, lo := ts/nanosPerSecond, ts%nanosPerSecond
+	sec := ts*wholeSeconds + hi*fraction + lo*fraction/nanosPerSecond
+	nsec := lo * fraction % nanosPerSecond
+	return time.Unix(b.epochNanos/nanosPerSecond+sec, b.epochNanos%nanosPerSecond+nsec).UTC()
+}
+
+// duration returns the length of n ticks.
+func (b timeBase) duration(n int64) time.Duration {
+	return time.Duration(n * b.tickNanos)
+}
+
+// ticksCeil returns the number of ticks needed to cover d, rounded up.
+func (b timeBase) ticksCeil(d time.Duration) int64 {
+	return (int64(d) + b.tickNanos - 1) / b.tickNanos
+}
+
+// maxValue returns the largest value representable with the given number of bits.
+func maxValue(bits uint8) int64 {
+	return -1 ^ (-1 << bits)
+}
@@ -0,0 +1,175 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Errors reported by leases.
+var (
+	// ErrLeaseLost is reported once a lease could not be renewed before it expired.
+	ErrLeaseLost = errors.New("machine ID lease lost")
+	// ErrLeaseReleased is reported once a lease has been released.
+	ErrLeaseReleased = errors.New("machine ID lease released")
+)
+
+// Store keeps the claims that leases are held on, such as the MySQL lease
+// table or the claim files of package registry.
+type Store interface {
+	// Renew extends the claim on machineID by the lease TTL, measured from
+	// no earlier than the call. It reports false if the claim is gone or
+	// held by someone else.
+	Renew(ctx context.Context, machineID int64) (bool, error)
+	// Release gives the claim on machineID up, if it is still held.
+	Release(ctx context.Context, machineID int64) error
+}
+
+// Lease is a held claim on a machine ID. It implements snowflake.MachineIDLease.
+type Lease struct {
+	// Where the claim is kept.
+	store Store
+	// The leased machine ID.
+	machineID int64
+	// How long the claim stays valid after a renewal.
+	ttl time.Duration
+	// How often the claim is renewed.
+	heartbeatInterval time.Duration
+
+	// Mutex to protect the fields below.
+	mu sync.Mutex
+	// The local time after which the claim may have expired in the store.
+	deadline time.Time
+	// Set once the lease is lost or released.
+	err error
+
+	// Stops the heartbeat goroutine.
+	cancel context.CancelFunc
+	// Closed when the heartbeat goroutine has exited.
+	done chan struct{}
+	// Closed when the lease is lost.
+	lost chan struct{}
+}
+
+// New returns a lease on a claim just written to store, and renews it
+// every heartbeatInterval until it is released or lost. deadline is the
+// local time after which the claim may have expired; it must not be later
+// than the expiry the store recorded.
+func New(store Store, machineID int64, deadline time.Time, ttl, heartbeatInterval time.Duration) *Lease {
+	heartbeatCtx, cancel := context.WithCancel(context.Background())
+	l := &Lease{
+		store:             store,
+		machineID:         machineID,
+		ttl:               ttl,
+		heartbeatInterval: heartbeatInterval,
+		deadline:          deadline,
+		cancel:            cancel,
+		done:              make(chan struct{}),
+		lost:              make(chan struct{}),
+	}
+	go l.heartbeat(heartbeatCtx)
+	return l
+}
+
+// MachineID returns the leased machine ID.
+func (l *Lease) MachineID() int64 {
+	return l.machineID
+}
+
+// Err returns nil while the lease is held. It returns ErrLeaseLost once a
+// renewal failed or the last successful renewal is older than the TTL, and
+// ErrLeaseReleased after Release.
+func (l *Lease) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil && time.Now().After(l.deadline) {
+		l.markLostLocked()
+	}
+	return l.err
+}
+
+// Lost returns a channel that is closed when the lease is lost.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release stops the heartbeat and gives the machine ID back.
+func (l *Lease) Release(ctx context.Context) error {
+	l.cancel()
+	<-l.done
+
+	l.mu.Lock()
+	if l.err == nil {
+		l.err = ErrLeaseReleased
+	}
+	l.mu.Unlock()
+
+	if err := l.store.Release(ctx, l.machineID); err != nil {
+		return fmt.Errorf("failed to release machine ID %d: %w", l.machineID, err)
+	}
+	log.Printf("Released machine ID %d", l.machineID)
+	return nil
+}
+
+// heartbeat renews the claim every heartbeat interval until ctx is cancelled
+// or the claim is lost.
+func (l *Lease) heartbeat(ctx context.Context) {
+	defer close(l.done)
+	ticker := time.NewTicker(l.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		// Give up on a renewal that could not succeed before the deadline anyway.
+		renewCtx, cancel := context.WithDeadline(ctx, l.currentDeadline())
+		held, err := l.store.Renew(renewCtx, l.machineID)
+		cancel()
+
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil:
+			// Transient errors are retried until the deadline passes; Err
+			// reports the loss once it does.
+			log.Printf("Error renewing lease on machine ID %d: %v", l.machineID, err)
+			if l.Err() != nil {
+				return
+			}
+		case !held:
+			log.Printf("Lease on machine ID %d was taken over or expired", l.machineID)
+			l.mu.Lock()
+			l.markLostLocked()
+			l.mu.Unlock()
+			return
+		default:
+			l.mu.Lock()
+			l.deadline = start.Add(l.ttl)
+			l.mu.Unlock()
+		}
+	}
+}
+
+// currentDeadline returns the local lease deadline.
+func (l *Lease) currentDeadline() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.deadline
+}
+
+// markLostLocked records the loss of the lease. It must be called with mu held.
+func (l *Lease) markLostLocked() {
+	if l.err != nil {
+		return
+	}
+	l.err = fmt.Errorf("%w: not renewed since %s", ErrLeaseLost, l.deadline.Add(-l.ttl).Format(time.RFC3339Nano))
+	close(l.lost)
+}
@@ -0,0 +1,234 @@
+// Package lease hands out machine IDs from a shared MySQL table so that two
+// generators never use the same machine ID at the same time.
+//
+// Each row of the lease table is a claim on one machine ID by one owner,
+// valid until its expiry time. A holder renews its claim on a heartbeat and
+// releases it on shutdown; a claim that is not renewed in time expires and
+// its machine ID becomes free again. All expiry arithmetic uses the MySQL
+// server clock, so the hosts' clocks do not need to agree.
+//
+// Lease and its heartbeat only depend on the Store interface, which MySQL
+// implements; package registry keeps its claims in files behind the same
+// Lease.
+//
+// The package uses database/sql and recognizes the errors of the MySQL
+// driver (github.com/go-sql-driver/mysql), which importing it registers.
+package lease
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/go-sql-driver/mysql"
+)
+
+// Defaults used when a Config field is left empty.
+const (
+	DefaultTable = "snowflake_machine_leases"
+	DefaultTTL   = 30 * time.Second
+)
+
+// ErrNoFreeMachineID is returned when every machine ID is leased.
+var ErrNoFreeMachineID = errors.New("no free machine ID")
+
+// maxClaimAttempts bounds how often Acquire retries a claim that lost a race
+// with a concurrent acquirer for the same machine ID.
+const maxClaimAttempts = 5
+
+// errDuplicateEntry is the MySQL error number of a duplicate key.
+const errDuplicateEntry = 1062
+
+// tableNamePattern restricts table names to plain identifiers, since they are
+// interpolated into SQL statements.
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Config configures a MySQL leaser.
+type Config struct {
+	// The lease table (DefaultTable if empty).
+	Table string
+	// Identifies the holder in the table (hostname and PID if empty).
+	Owner string
+	// How long a claim stays valid without renewal (DefaultTTL if zero).
+	TTL time.Duration
+	// How often claims are renewed (a third of TTL if zero).
+	HeartbeatInterval time.Duration
+	// The layout whose machine IDs are handed out (snowflake.DefaultLayout if zero).
+	Layout snowflake.Layout
+}
+
+// MySQL leases machine IDs from a MySQL table.
+type MySQL struct {
+	// The database holding the lease table.
+	db *sql.DB
+	// The configuration, with defaults filled in.
+	cfg Config
+}
+
+// NewMySQL returns a leaser using the lease table in db.
+func NewMySQL(db *sql.DB, cfg Config) (*MySQL, error) {
+	// Fill in the defaults.
+	if cfg.Table == "" {
+		cfg.Table = DefaultTable
+	}
+	if !tableNamePattern.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("invalid lease table name %q", cfg.Table)
+	}
+	if cfg.Owner == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname for lease owner: %w", err)
+		}
+		cfg.Owner = hostname + ":" + strconv.Itoa(os.Getpid())
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = cfg.TTL / 3
+	}
+	if cfg.HeartbeatInterval >= cfg.TTL {
+		return nil, fmt.Errorf("heartbeat interval %v must be shorter than the TTL %v", cfg.HeartbeatInterval, cfg.TTL)
+	}
+	if cfg.Layout == (snowflake.Layout{}) {
+		cfg.Layout = snowflake.DefaultLayout
+	}
+	return &MySQL{db: db, cfg: cfg}, nil
+}
+
+// EnsureTable creates the lease table if it does not exist yet.
+func (m *MySQL) EnsureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.cfg.Table+` (
+		machine_id INT NOT NULL PRIMARY KEY,
+		owner VARCHAR(255) NOT NULL,
+		acquired_at DATETIME(3) NOT NULL,
+		expires_at DATETIME(3) NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create lease table %s: %w", m.cfg.Table, err)
+	}
+	return nil
+}
+
+// Acquire claims the lowest free machine ID and starts renewing the claim in
+// the background until the lease is released or lost.
+func (m *MySQL) Acquire(ctx context.Context) (*Lease, error) {
+	// Measure the local deadline from before the claim is written, so it is
+	// never later than the one stored in the table.
+	var start time.Time
+	var machineID int64
+	var err error
+	for attempt := 1; ; attempt++ {
+		start = time.Now()
+		machineID, err = m.claim(ctx)
+		// FOR UPDATE cannot lock rows that do not exist yet, so two acquirers
+		// may both try to insert the same free machine ID; the loser retries
+		// and sees the winner's row.
+		if !isDuplicateEntry(err) || attempt == maxClaimAttempts {
+			break
+		}
+		log.Printf("Lost the race for a machine ID lease, retrying: %v", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := New(m, machineID, start.Add(m.cfg.TTL), m.cfg.TTL, m.cfg.HeartbeatInterval)
+	log.Printf("Leased machine ID %d as %s (ttl %v)", machineID, m.cfg.Owner, m.cfg.TTL)
+	return l, nil
+}
+
+// claim picks and writes a claim on the lowest machine ID without a live
+// claim, in a transaction that locks the lease table.
+func (m *MySQL) claim(ctx context.Context) (int64, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin lease transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the existing rows so concurrent acquirers are serialized on them.
+	// Machine IDs without a row cannot be locked; races for those end in a
+	// duplicate key error on insert, which Acquire retries.
+	rows, err := tx.QueryContext(ctx, `SELECT machine_id, expires_at > NOW(3) FROM `+m.cfg.Table+` ORDER BY machine_id FOR UPDATE`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read lease table: %w", err)
+	}
+	live := make(map[int64]bool)
+	known := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		var alive bool
+		if err := rows.Scan(&id, &alive); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read lease table: %w", err)
+		}
+		known[id] = true
+		live[id] = alive
+	}
+	if err := rows.Close(); err != nil {
+		return 0, fmt.Errorf("failed to read lease table: %w", err)
+	}
+
+	// Take the lowest machine ID that has no live claim.
+	for id := int64(0); id <= m.cfg.Layout.MaxMachineID(); id++ {
+		if live[id] {
+			continue
+		}
+		ttl := m.cfg.TTL.Microseconds()
+		if known[id] {
+			_, err = tx.ExecContext(ctx, `UPDATE `+m.cfg.Table+`
+				SET owner = ?, acquired_at = NOW(3), expires_at = NOW(3) + INTERVAL ? MICROSECOND
+				WHERE machine_id = ?`, m.cfg.Owner, ttl, id)
+		} else {
+			_, err = tx.ExecContext(ctx, `INSERT INTO `+m.cfg.Table+` (machine_id, owner, acquired_at, expires_at)
+				VALUES (?, ?, NOW(3), NOW(3) + INTERVAL ? MICROSECOND)`, id, m.cfg.Owner, ttl)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to claim machine ID %d: %w", id, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, fmt.Errorf("failed to commit claim on machine ID %d: %w", id, err)
+		}
+		return id, nil
+	}
+	return 0, fmt.Errorf("%w: all %d machine IDs are leased", ErrNoFreeMachineID, m.cfg.Layout.MaxMachineID()+1)
+}
+
+// isDuplicateEntry reports whether err is MySQL's duplicate key error.
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
+
+// Renew implements Store. It extends the claim on machineID if it is still
+// held by this owner and has not expired.
+func (m *MySQL) Renew(ctx context.Context, machineID int64) (bool, error) {
+	res, err := m.db.ExecContext(ctx, `UPDATE `+m.cfg.Table+`
+		SET expires_at = NOW(3) + INTERVAL ? MICROSECOND
+		WHERE machine_id = ? AND owner = ? AND expires_at > NOW(3)`, m.cfg.TTL.Microseconds(), machineID, m.cfg.Owner)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Release implements Store. It expires the claim on machineID right away;
+// the row is kept so the table shows who held the machine ID last.
+func (m *MySQL) Release(ctx context.Context, machineID int64) error {
+	_, err := m.db.ExecContext(ctx, `UPDATE `+m.cfg.Table+`
+		SET expires_at = NOW(3)
+		WHERE machine_id = ? AND owner = ?`, machineID, m.cfg.Owner)
+	return err
+}
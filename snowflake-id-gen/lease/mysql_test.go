@@ -0,0 +1,342 @@
+package lease
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/go-sql-driver/mysql"
+)
+
+// stubTable is an in-memory lease table served by stubDriver. It understands
+// exactly the statements MySQL issues, matched by their text, and evaluates
+// NOW(3) against its own clock so tests can expire claims at will.
+type stubTable struct {
+	mu sync.Mutex
+	// The server clock.
+	now time.Time
+	// The rows by machine ID.
+	rows map[int64]*stubRow
+	// How many upcoming inserts lose a race against a concurrent acquirer.
+	racedInserts int
+	// Successful renewals.
+	renewals int
+}
+
+// stubRow is a row of stubTable.
+type stubRow struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// stubTables maps the DSNs passed to sql.Open to their tables.
+var stubTables sync.Map
+
+func init() {
+	sql.Register("leasestub", stubDriver{})
+}
+
+// openStub returns a MySQL leaser on a fresh stub table.
+func openStub(t *testing.T, owner string, ttl, heartbeat time.Duration) (*MySQL, *stubTable) {
+	t.Helper()
+	table := &stubTable{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), rows: make(map[int64]*stubRow)}
+	stubTables.Store(t.Name(), table)
+	return newStubLeaser(t, owner, ttl, heartbeat), table
+}
+
+// newStubLeaser returns another leaser on the stub table of the test.
+func newStubLeaser(t *testing.T, owner string, ttl, heartbeat time.Duration) *MySQL {
+	t.Helper()
+	db, err := sql.Open("leasestub", t.Name())
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	layout := snowflake.Layout{TimestampBits: 61, WorkerBits: 3, SequenceBits: 0, Epoch: snowflake.DefaultEpoch}
+	m, err := NewMySQL(db, Config{Owner: owner, TTL: ttl, HeartbeatInterval: heartbeat, Layout: layout})
+	if err != nil {
+		t.Fatalf("NewMySQL: %v", err)
+	}
+	if err := m.EnsureTable(context.Background()); err != nil {
+		t.Fatalf("EnsureTable: %v", err)
+	}
+	return m
+}
+
+// advance moves the server clock.
+func (tbl *stubTable) advance(d time.Duration) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	tbl.now = tbl.now.Add(d)
+}
+
+// row returns a copy of the row of machineID.
+func (tbl *stubTable) row(machineID int64) (stubRow, bool) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	r, ok := tbl.rows[machineID]
+	if !ok {
+		return stubRow{}, false
+	}
+	return *r, true
+}
+
+// exec runs a statement against the table. Transactions are not isolated;
+// the leaser commits every transaction right after its last statement.
+func (tbl *stubTable) exec(query string, args []driver.Value) (driver.Rows, int64, error) {
+	tbl.mu.Lock()
+	defer tbl.mu.Unlock()
+	query = strings.Join(strings.Fields(query), " ")
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+		return nil, 0, nil
+	case strings.HasPrefix(query, "SELECT machine_id"):
+		rows := &stubRows{}
+		for id, r := range tbl.rows {
+			rows.values = append(rows.values, []driver.Value{id, r.expiresAt.After(tbl.now)})
+		}
+		sort.Slice(rows.values, func(i, j int) bool { return rows.values[i][0].(int64) < rows.values[j][0].(int64) })
+		return rows, 0, nil
+	case strings.HasPrefix(query, "INSERT INTO"):
+		id, owner, ttl := args[0].(int64), args[1].(string), args[2].(int64)
+		if tbl.racedInserts > 0 {
+			// A concurrent acquirer inserted the row first.
+			tbl.racedInserts--
+			tbl.rows[id] = &stubRow{owner: "racer", expiresAt: tbl.now.Add(time.Hour)}
+		}
+		if _, ok := tbl.rows[id]; ok {
+			return nil, 0, &mysql.MySQLError{Number: 1062, Message: fmt.Sprintf("Duplicate entry '%d' for key 'PRIMARY'", id)}
+		}
+		tbl.rows[id] = &stubRow{owner: owner, expiresAt: tbl.now.Add(time.Duration(ttl) * time.Microsecond)}
+		return nil, 1, nil
+	case strings.Contains(query, "SET owner = ?"):
+		owner, ttl, id := args[0].(string), args[1].(int64), args[2].(int64)
+		tbl.rows[id] = &stubRow{owner: owner, expiresAt: tbl.now.Add(time.Duration(ttl) * time.Microsecond)}
+		return nil, 1, nil
+	case strings.Contains(query, "SET expires_at = NOW(3) + INTERVAL"):
+		ttl, id, owner := args[0].(int64), args[1].(int64), args[2].(string)
+		r, ok := tbl.rows[id]
+		if !ok || r.owner != owner || !r.expiresAt.After(tbl.now) {
+			return nil, 0, nil
+		}
+		r.expiresAt = tbl.now.Add(time.Duration(ttl) * time.Microsecond)
+		tbl.renewals++
+		return nil, 1, nil
+	case strings.Contains(query, "SET expires_at = NOW(3) WHERE"):
+		id, owner := args[0].(int64), args[1].(string)
+		if r, ok := tbl.rows[id]; ok && r.owner == owner {
+			r.expiresAt = tbl.now
+			return nil, 1, nil
+		}
+		return nil, 0, nil
+	}
+	return nil, 0, fmt.Errorf("stub: unexpected query %q", query)
+}
+
+// stubDriver serves stub tables through database/sql.
+type stubDriver struct{}
+
+func (stubDriver) Open(dsn string) (driver.Conn, error) {
+	table, ok := stubTables.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("stub: no table %q", dsn)
+	}
+	return stubConn{table.(*stubTable)}, nil
+}
+
+type stubConn struct{ table *stubTable }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) {
+	return stubStmt{c.table, query}, nil
+}
+func (stubConn) Close() error              { return nil }
+func (stubConn) Begin() (driver.Tx, error) { return stubTx{}, nil }
+
+type stubTx struct{}
+
+func (stubTx) Commit() error   { return nil }
+func (stubTx) Rollback() error { return nil }
+
+type stubStmt struct {
+	table *stubTable
+	query string
+}
+
+func (stubStmt) Close() error  { return nil }
+func (stubStmt) NumInput() int { return -1 }
+func (s stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, n, err := s.table.exec(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+func (s stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.table.exec(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	if rows == nil {
+		return &stubRows{}, nil
+	}
+	return rows, nil
+}
+
+type stubRows struct{ values [][]driver.Value }
+
+func (*stubRows) Columns() []string { return []string{"machine_id", "live"} }
+func (*stubRows) Close() error      { return nil }
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestAcquirePicksLowestFreeMachineID(t *testing.T) {
+	a, table := openStub(t, "a", time.Minute, time.Second)
+	b := newStubLeaser(t, "b", time.Minute, time.Second)
+	ctx := context.Background()
+
+	for i := 0; i < 8; i++ {
+		// Alternate between the two owners.
+		m := a
+		if i%2 == 1 {
+			m = b
+		}
+		l, err := m.Acquire(ctx)
+		if err != nil {
+			t.Fatalf("Acquire %d: %v", i, err)
+		}
+		defer l.Release(ctx)
+		if l.MachineID() != int64(i) {
+			t.Errorf("Acquire %d leased machine ID %d", i, l.MachineID())
+		}
+		if r, _ := table.row(int64(i)); r.owner != m.cfg.Owner {
+			t.Errorf("machine ID %d is owned by %q, want %q", i, r.owner, m.cfg.Owner)
+		}
+	}
+	// The layout has eight machine IDs.
+	if _, err := b.Acquire(ctx); !errors.Is(err, ErrNoFreeMachineID) {
+		t.Errorf("Acquire with every machine ID leased: got %v, want ErrNoFreeMachineID", err)
+	}
+}
+
+func TestAcquireRetriesDuplicateEntry(t *testing.T) {
+	m, table := openStub(t, "a", time.Minute, time.Second)
+	ctx := context.Background()
+
+	// Machine ID 0 goes to a concurrent acquirer; the retry takes 1.
+	table.racedInserts = 1
+	l, err := m.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire after a lost race: %v", err)
+	}
+	defer l.Release(ctx)
+	if l.MachineID() != 1 {
+		t.Errorf("Acquire after a lost race leased machine ID %d, want 1", l.MachineID())
+	}
+
+	// Retries are bounded.
+	table.racedInserts = maxClaimAttempts
+	if _, err := m.Acquire(ctx); !isDuplicateEntry(err) {
+		t.Errorf("Acquire losing every race: got %v, want a duplicate key error", err)
+	}
+}
+
+func TestLeaseRenewal(t *testing.T) {
+	m, table := openStub(t, "a", 100*time.Millisecond, 20*time.Millisecond)
+	ctx := context.Background()
+	l, err := m.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer l.Release(ctx)
+
+	// Outlive the TTL several times; only the heartbeat keeps the lease.
+	time.Sleep(300 * time.Millisecond)
+	if err := l.Err(); err != nil {
+		t.Fatalf("Err after renewals: %v", err)
+	}
+	table.mu.Lock()
+	renewals := table.renewals
+	table.mu.Unlock()
+	if renewals < 3 {
+		t.Errorf("%d renewals in three TTLs, want at least 3", renewals)
+	}
+}
+
+func TestLeaseExpiryTakeover(t *testing.T) {
+	a, table := openStub(t, "a", time.Minute, 10*time.Millisecond)
+	b := newStubLeaser(t, "b", time.Minute, time.Second)
+	ctx := context.Background()
+	la, err := a.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire a: %v", err)
+	}
+	defer la.Release(ctx)
+
+	// a's claim expires on the server, as if a had stalled; b takes over.
+	table.advance(2 * time.Minute)
+	lb, err := b.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire b: %v", err)
+	}
+	defer lb.Release(ctx)
+	if lb.MachineID() != la.MachineID() {
+		t.Errorf("b leased machine ID %d, want the expired %d", lb.MachineID(), la.MachineID())
+	}
+
+	// a's next heartbeat finds the claim gone.
+	select {
+	case <-la.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("a's lease was not lost after the takeover")
+	}
+	if err := la.Err(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("a's Err = %v, want ErrLeaseLost", err)
+	}
+	if err := lb.Err(); err != nil {
+		t.Errorf("b's Err = %v, want nil", err)
+	}
+}
+
+func TestLeaseRelease(t *testing.T) {
+	a, table := openStub(t, "a", time.Minute, 10*time.Millisecond)
+	b := newStubLeaser(t, "b", time.Minute, time.Second)
+	ctx := context.Background()
+	la, err := a.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire a: %v", err)
+	}
+	if err := la.Release(ctx); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := la.Err(); !errors.Is(err, ErrLeaseReleased) {
+		t.Errorf("Err after Release = %v, want ErrLeaseReleased", err)
+	}
+	// The row is kept, expired, so the machine ID is free again.
+	r, ok := table.row(la.MachineID())
+	if !ok || r.owner != "a" || r.expiresAt.After(table.now) {
+		t.Errorf("row after Release = %+v (found %v), want a's expired claim", r, ok)
+	}
+	lb, err := b.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire b: %v", err)
+	}
+	defer lb.Release(ctx)
+	if lb.MachineID() != la.MachineID() {
+		t.Errorf("b leased machine ID %d, want the released %d", lb.MachineID(), la.MachineID())
+	}
+}
@@ -0,0 +1,83 @@
+package snowflake
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// WithLifetimeWarning calls hook once, with the remaining lifetime, the first
+// time the generator issues an ID less than threshold before the layout's
+// timestamp is exhausted (see Layout.ExhaustionTime). A nil hook logs a
+// warning instead. Once the timestamp is exhausted, generators fail with
+// ErrEpochExhausted regardless of this option.
+func WithLifetimeWarning(threshold time.Duration, hook func(remaining time.Duration)) Option {
+	return func(s *Snowflake) {
+		s.lifetime = &lifetimeWarning{threshold: threshold, hook: hook}
+	}
+}
+
+// Remaining returns how long the generator can issue IDs before its layout's
+// timestamp is exhausted, according to its clock.
+func (s *Snowflake) Remaining() time.Duration {
+	return s.layout.Remaining(s.clock.Now())
+}
+
+// lifetimeWarning fires a hook once, the first time an ID is issued with less
+// than the threshold of the layout's lifetime left.
+type lifetimeWarning struct {
+	// How much lifetime must be left to stay quiet.
+	threshold time.Duration
+	// Called with the remaining lifetime.
+	hook func(remaining time.Duration)
+	// The first timestamp that triggers the warning.
+	from int64
+	// Converts timestamps back to times.
+	base timeBase
+	// When the layout's timestamp is exhausted.
+	exhaustion time.Time
+	// Whether the warning has fired.
+	fired atomic.Bool
+}
+
+// init derives the trigger timestamp from the threshold and the layout.
+func (w *lifetimeWarning) init(l Layout) {
+	w.base = newTimeBase(l)
+	w.exhaustion = l.ExhaustionTime()
+	w.from = l.MaxTimestamp() + 1 - w.base.ticksCeil(w.threshold)
+	if w.hook == nil {
+		w.hook = func(remaining time.Duration) {
+			log.Printf("Warning: the layout's timestamp is exhausted in %s (at %s)",
+				formatYears(remaining), w.exhaustion.Format(time.RFC3339))
+		}
+	}
+}
+
+// check fires the warning if an ID with timestamp ts is within the
+// threshold of exhaustion. It is a no-op on a nil warning.
+func (w *lifetimeWarning) check(ts int64) {
+	if w == nil || ts < w.from || w.fired.Load() || !w.fired.CompareAndSwap(false, true) {
+		return
+	}
+	w.hook(w.exhaustion.Sub(w.base.time(ts)))
+}
+
+// checkExhausted returns ErrEpochExhausted if ts does not fit into the
+// layout's timestamp bits.
+func (s *Snowflake) checkExhausted(ts int64) error {
+	if ts > s.maxTimestamp {
+		return fmt.Errorf("%w: timestamps ran out at %s", ErrEpochExhausted, s.layout.ExhaustionTime().Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// formatYears formats a long duration in years, or as a duration if it is
+// shorter than a year.
+func formatYears(d time.Duration) string {
+	const year = 365.25 * 24 * time.Hour
+	if d < year {
+		return d.Round(time.Millisecond).String()
+	}
+	return fmt.Sprintf("%.1f years", d.Hours()/year.Hours())
+}
@@ -0,0 +1,86 @@
+package snowflake
+
+import (
+	"fmt"
+	"log"
+	"net"
+)
+
+// MachineIDFromMAC attempts to derive a suitable machine ID from network interfaces.
+// The last two bytes of the first usable MAC address are masked to fit MaxMachineID.
+func MachineIDFromMAC() (int64, error) {
+	return MachineIDFromMACForLayout(DefaultLayout)
+}
+
+// MachineIDFromMACForLayout is like MachineIDFromMAC but masks the derived ID
+// to the machine ID bits of the given layout.
+func MachineIDFromMACForLayout(l Layout) (int64, error) {
+	// Get a list of all network interfaces on the system.
+	interfaces, err := net.Interfaces()
+	// Handle potential errors during interface retrieval.
+	if err != nil {
+		return 0, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	// Iterate through the list of interfaces.
+	for _, iface := range interfaces {
+		// Skip loopback interfaces (e.g., 'lo').
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		// Skip interfaces that are down.
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		// Get the hardware address (MAC address) of the interface.
+		mac := iface.HardwareAddr
+		// Skip interfaces without a valid MAC address (e.g., virtual interfaces).
+		if len(mac) == 0 {
+			continue
+		}
+
+		// Derive the machine ID from the MAC address.
+		machineID, derivedID := machineIDFromHardwareAddr(mac, l)
+
+		// Log the interface name, MAC, derived ID (before mask), and final machine ID.
+		log.Printf("Using interface: %s, MAC: %s, Derived ID (raw): %d, Final Machine ID: %d", iface.Name, mac.String(), derivedID, machineID)
+
+		// Return the first valid machine ID found.
+		return machineID, nil
+	}
+
+	// If no suitable interface was found after checking all, return an error.
+	return 0, ErrNoSuitableInterface
+}
+
+// MachineIDFromHardwareAddr derives a machine ID from a MAC address exactly
+// as MachineIDFromMACForLayout does for the first usable interface. It is
+// useful for predicting which machines of a fleet will collide.
+func MachineIDFromHardwareAddr(mac net.HardwareAddr, l Layout) (int64, error) {
+	if len(mac) == 0 {
+		return 0, fmt.Errorf("%w: empty hardware address", ErrNoSuitableInterface)
+	}
+	machineID, _ := machineIDFromHardwareAddr(mac, l)
+	return machineID, nil
+}
+
+// machineIDFromHardwareAddr returns the machine ID derived from a non-empty
+// MAC address, together with the raw value before masking.
+func machineIDFromHardwareAddr(mac net.HardwareAddr, l Layout) (machineID, derivedID int64) {
+	// Use the last two bytes of the MAC address for the machine ID.
+	// This provides 16 bits of potential variation, which is more than
+	// the 10 bits of the default layout, reducing collision probability locally.
+	// Note: This does NOT guarantee global uniqueness across datacenters
+	// or different network segments without careful management.
+	if len(mac) >= 2 {
+		// Combine the last two bytes into an int64.
+		// Shift the second-to-last byte left by 8 bits and OR with the last byte.
+		derivedID = int64(mac[len(mac)-2])<<8 | int64(mac[len(mac)-1])
+	} else {
+		// If only one byte, use that. Less ideal.
+		derivedID = int64(mac[len(mac)-1])
+	}
+
+	// Ensure the derived ID fits within the allocated bits using a bitmask.
+	return derivedID & l.MaxMachineID(), derivedID
+}
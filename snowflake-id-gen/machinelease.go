@@ -0,0 +1,41 @@
+package snowflake
+
+import "fmt"
+
+// MachineIDLease is a time-limited, exclusive claim on a machine ID, such as
+// the MySQL leases of package lease. A generator holding a lease refuses to
+// issue IDs once the lease reports an error, because another generator may
+// already be using the same machine ID.
+type MachineIDLease interface {
+	// MachineID returns the leased machine ID.
+	MachineID() int64
+	// Err returns nil while the lease is held and a non-nil error once it
+	// has been lost, has expired or has been released.
+	Err() error
+}
+
+// WithLease ties the generator to a machine ID lease. The machine ID passed
+// to NewSnowflake must be the leased one.
+func WithLease(lease MachineIDLease) Option {
+	return func(s *Snowflake) {
+		s.lease = lease
+	}
+}
+
+// LeaseErr returns the error of the generator's machine ID lease, or nil if
+// the lease is held or the generator has none.
+func (s *Snowflake) LeaseErr() error {
+	if s.lease == nil {
+		return nil
+	}
+	return s.lease.Err()
+}
+
+// checkLease returns a non-nil error if the generator must stop issuing IDs
+// because its machine ID lease is no longer held.
+func (s *Snowflake) checkLease() error {
+	if err := s.LeaseErr(); err != nil {
+		return fmt.Errorf("machine ID %d: %w", s.lease.MachineID(), err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// DefaultTickWaitBuckets are the upper bounds, in seconds, of the tick wait
+// histogram. Waits are at most one tick long unless the clock stalls.
+var DefaultTickWaitBuckets = []float64{0.00001, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.1}
+
+// DefaultRollbackBuckets are the upper bounds, in seconds, of the clock
+// rollback distance histogram.
+var DefaultRollbackBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 30}
+
+// Generator collects the metrics of one or more generators. It implements
+// snowflake.Observer, so it is attached with snowflake.WithObserver, and
+// http.Handler, serving the metrics in the Prometheus text format:
+//
+//	snowflake_ids_issued_total            counter
+//	snowflake_sequence_overflows_total    counter
+//	snowflake_tick_wait_seconds           histogram
+//	snowflake_clock_rollbacks_total       counter by strategy and result
+//	snowflake_clock_rollback_seconds      histogram
+//	snowflake_timestamp_lag_seconds       gauge
+//	snowflake_epoch_remaining_seconds     gauge
+type Generator struct {
+	// IDs issued.
+	IDsIssued Counter
+	// Ticks whose sequence was exhausted.
+	SequenceOverflows Counter
+	// Time spent waiting for the next tick after a sequence overflow.
+	TickWait *Histogram
+	// How far the clock moved backwards, per detected regression.
+	RollbackDistance *Histogram
+
+	// Mutex to protect rollbacks.
+	mu sync.Mutex
+	// Clock regressions by strategy and result.
+	rollbacks map[rollbackKey]uint64
+
+	// Lag, if set, is called on every scrape for the lag gauge; set it to
+	// (*snowflake.Snowflake).Lag of the instrumented generator before serving.
+	Lag func() time.Duration
+	// Remaining, if set, is called on every scrape for the remaining
+	// lifetime gauge; set it to (*snowflake.Snowflake).Remaining.
+	Remaining func() time.Duration
+}
+
+// rollbackKey labels the clock rollback counter.
+type rollbackKey struct {
+	strategy string
+	result   string
+}
+
+// NewGenerator returns an empty metrics collector.
+func NewGenerator() *Generator {
+	return &Generator{
+		TickWait:         NewHistogram(DefaultTickWaitBuckets...),
+		RollbackDistance: NewHistogram(DefaultRollbackBuckets...),
+		rollbacks:        make(map[rollbackKey]uint64),
+	}
+}
+
+// ObserveIDs counts n issued IDs.
+func (g *Generator) ObserveIDs(n int) {
+	g.IDsIssued.Add(uint64(n))
+}
+
+// ObserveSequenceOverflow counts an exhausted tick.
+func (g *Generator) ObserveSequenceOverflow() {
+	g.SequenceOverflows.Add(1)
+}
+
+// ObserveTickWait records the time spent waiting for the next tick.
+func (g *Generator) ObserveTickWait(d time.Duration) {
+	g.TickWait.Observe(d.Seconds())
+}
+
+// ObserveRollback counts a clock regression and records its distance.
+func (g *Generator) ObserveRollback(e snowflake.RollbackEvent) {
+	g.RollbackDistance.Observe(e.Backwards.Seconds())
+	key := rollbackKey{strategy: e.Strategy.String(), result: "absorbed"}
+	if e.Err != nil {
+		key.result = "failed"
+	}
+	g.mu.Lock()
+	g.rollbacks[key]++
+	g.mu.Unlock()
+}
+
+// WritePrometheus writes all metrics to w in the Prometheus text format.
+func (g *Generator) WritePrometheus(w io.Writer) error {
+	if err := writeCounter(w, "snowflake_ids_issued_total", "IDs issued.", g.IDsIssued.Value()); err != nil {
+		return err
+	}
+	if err := writeCounter(w, "snowflake_sequence_overflows_total", "Ticks whose sequence space was exhausted.", g.SequenceOverflows.Value()); err != nil {
+		return err
+	}
+	if err := writeHistogram(w, "snowflake_tick_wait_seconds", "Time spent waiting for the next tick after a sequence overflow.", g.TickWait); err != nil {
+		return err
+	}
+	if err := g.writeRollbacks(w); err != nil {
+		return err
+	}
+	if err := writeHistogram(w, "snowflake_clock_rollback_seconds", "How far the clock moved backwards per detected regression.", g.RollbackDistance); err != nil {
+		return err
+	}
+	if g.Lag != nil {
+		if err := writeGauge(w, "snowflake_timestamp_lag_seconds", "Time between the clock and the timestamp of the last issued ID.", g.Lag().Seconds()); err != nil {
+			return err
+		}
+	}
+	if g.Remaining != nil {
+		if err := writeGauge(w, "snowflake_epoch_remaining_seconds", "Time until the timestamp of the layout is exhausted.", g.Remaining().Seconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRollbacks writes the labelled clock rollback counter, sorted by labels.
+func (g *Generator) writeRollbacks(w io.Writer) error {
+	const name = "snowflake_clock_rollbacks_total"
+	g.mu.Lock()
+	keys := make([]rollbackKey, 0, len(g.rollbacks))
+	counts := make(map[rollbackKey]uint64, len(g.rollbacks))
+	for k, v := range g.rollbacks {
+		keys = append(keys, k)
+		counts[k] = v
+	}
+	g.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].strategy != keys[j].strategy {
+			return keys[i].strategy < keys[j].strategy
+		}
+		return keys[i].result < keys[j].result
+	})
+
+	if err := writeHeader(w, name, "Clock regressions detected, by rollback strategy and result.", "counter"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s{strategy=%q,result=%q} %d\n", name, k.strategy, k.result, counts[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (g *Generator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	if err := g.WritePrometheus(bw); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+		return
+	}
+	if err := bw.Flush(); err != nil {
+		log.Printf("Error writing metrics: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// wantExposition is the exposition of the observations in TestGeneratorServeHTTP.
+const wantExposition = `# HELP snowflake_ids_issued_total IDs issued.
+# TYPE snowflake_ids_issued_total counter
+snowflake_ids_issued_total 7
+# HELP snowflake_sequence_overflows_total Ticks whose sequence space was exhausted.
+# TYPE snowflake_sequence_overflows_total counter
+snowflake_sequence_overflows_total 2
+# HELP snowflake_tick_wait_seconds Time spent waiting for the next tick after a sequence overflow.
+# TYPE snowflake_tick_wait_seconds histogram
+snowflake_tick_wait_seconds_bucket{le="1e-05"} 0
+snowflake_tick_wait_seconds_bucket{le="0.0001"} 0
+snowflake_tick_wait_seconds_bucket{le="0.00025"} 0
+snowflake_tick_wait_seconds_bucket{le="0.0005"} 1
+snowflake_tick_wait_seconds_bucket{le="0.001"} 1
+snowflake_tick_wait_seconds_bucket{le="0.0025"} 2
+snowflake_tick_wait_seconds_bucket{le="0.005"} 2
+snowflake_tick_wait_seconds_bucket{le="0.01"} 2
+snowflake_tick_wait_seconds_bucket{le="0.025"} 2
+snowflake_tick_wait_seconds_bucket{le="0.1"} 2
+snowflake_tick_wait_seconds_bucket{le="+Inf"} 2
+snowflake_tick_wait_seconds_sum 0.0025
+snowflake_tick_wait_seconds_count 2
+# HELP snowflake_clock_rollbacks_total Clock regressions detected, by rollback strategy and result.
+# TYPE snowflake_clock_rollbacks_total counter
+snowflake_clock_rollbacks_total{strategy="fail",result="failed"} 1
+snowflake_clock_rollbacks_total{strategy="wait",result="absorbed"} 1
+# HELP snowflake_clock_rollback_seconds How far the clock moved backwards per detected regression.
+# TYPE snowflake_clock_rollback_seconds histogram
+snowflake_clock_rollback_seconds_bucket{le="0.001"} 0
+snowflake_clock_rollback_seconds_bucket{le="0.005"} 1
+snowflake_clock_rollback_seconds_bucket{le="0.01"} 1
+snowflake_clock_rollback_seconds_bucket{le="0.05"} 1
+snowflake_clock_rollback_seconds_bucket{le="0.1"} 1
+snowflake_clock_rollback_seconds_bucket{le="0.5"} 1
+snowflake_clock_rollback_seconds_bucket{le="1"} 1
+snowflake_clock_rollback_seconds_bucket{le="5"} 2
+snowflake_clock_rollback_seconds_bucket{le="30"} 2
+snowflake_clock_rollback_seconds_bucket{le="+Inf"} 2
+snowflake_clock_rollback_seconds_sum 2.003
+snowflake_clock_rollback_seconds_count 2
+# HELP snowflake_timestamp_lag_seconds Time between the clock and the timestamp of the last issued ID.
+# TYPE snowflake_timestamp_lag_seconds gauge
+snowflake_timestamp_lag_seconds 1.5
+# HELP snowflake_epoch_remaining_seconds Time until the timestamp of the layout is exhausted.
+# TYPE snowflake_epoch_remaining_seconds gauge
+snowflake_epoch_remaining_seconds 3600
+`
+
+func TestGeneratorServeHTTP(t *testing.T) {
+	g := NewGenerator()
+	g.ObserveIDs(5)
+	g.ObserveIDs(2)
+	g.ObserveSequenceOverflow()
+	g.ObserveSequenceOverflow()
+	g.ObserveTickWait(500 * time.Microsecond)
+	g.ObserveTickWait(2 * time.Millisecond)
+	g.ObserveRollback(snowflake.RollbackEvent{Strategy: snowflake.RollbackWait, Backwards: 3 * time.Millisecond})
+	g.ObserveRollback(snowflake.RollbackEvent{Strategy: snowflake.RollbackFail, Backwards: 2 * time.Second, Err: errors.New("clock moved backwards")})
+	g.Lag = func() time.Duration { return 1500 * time.Millisecond }
+	g.Remaining = func() time.Duration { return time.Hour }
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Body.String(); got != wantExposition {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", got, wantExposition)
+	}
+}
+
+func TestGeneratorObservesSnowflake(t *testing.T) {
+	g := NewGenerator()
+	clock := snowflake.NewManualClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	// Two IDs per tick make the sequence easy to exhaust.
+	layout := snowflake.Layout{TimestampBits: 52, WorkerBits: 10, SequenceBits: 1, Epoch: snowflake.DefaultEpoch}
+	gen, err := snowflake.NewSnowflake(1, snowflake.WithClock(clock), snowflake.WithLayout(layout), snowflake.WithObserver(g))
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	for range 3 {
+		if _, err := gen.GenerateID(); err != nil {
+			t.Fatalf("GenerateID: %v", err)
+		}
+	}
+	clock.Advance(-10 * time.Millisecond)
+	if _, err := gen.GenerateID(); !errors.Is(err, snowflake.ErrClockMovedBackwards) {
+		t.Fatalf("GenerateID after a backwards jump: got %v", err)
+	}
+
+	var b strings.Builder
+	if err := g.WritePrometheus(&b); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	for _, line := range []string{
+		"snowflake_ids_issued_total 3\n",
+		"snowflake_sequence_overflows_total 1\n",
+		"snowflake_tick_wait_seconds_count 1\n",
+		`snowflake_clock_rollbacks_total{strategy="fail",result="failed"} 1` + "\n",
+		`snowflake_clock_rollback_seconds_bucket{le="0.005"} 0` + "\n",
+		`snowflake_clock_rollback_seconds_bucket{le="0.01"} 1` + "\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("exposition lacks %q:\n%s", line, b.String())
+		}
+	}
+	// The gauges are only written once their callbacks are set.
+	if strings.Contains(b.String(), "snowflake_timestamp_lag_seconds") {
+		t.Error("lag gauge written without a Lag callback")
+	}
+}
@@ -0,0 +1,131 @@
+// Package metrics instruments Snowflake generators and exposes the results
+// in the Prometheus text exposition format, without depending on a
+// Prometheus client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Add increases the counter by n.
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+// Value returns the current count.
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// Histogram counts observations in cumulative buckets with fixed upper bounds.
+type Histogram struct {
+	// Upper bounds of the buckets, in ascending order.
+	bounds []float64
+	// Observations per bucket (not cumulative); the last one is +Inf.
+	counts []atomic.Uint64
+	// Sum of all observations, as float64 bits.
+	sum atomic.Uint64
+}
+
+// NewHistogram returns a histogram with the given bucket upper bounds. The
+// +Inf bucket is added implicitly.
+func NewHistogram(bounds ...float64) *Histogram {
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	return &Histogram{bounds: bounds, counts: make([]atomic.Uint64, len(bounds)+1)}
+}
+
+// Observe records one observation.
+func (h *Histogram) Observe(v float64) {
+	// The first bucket whose upper bound is not below v.
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i].Add(1)
+	for {
+		old := h.sum.Load()
+		if h.sum.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	var n uint64
+	for i := range h.counts {
+		n += h.counts[i].Load()
+	}
+	return n
+}
+
+// Sum returns the sum of all observations.
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(h.sum.Load())
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric family.
+func writeHeader(w io.Writer, name, help, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return err
+}
+
+// writeCounter writes a counter family with a single unlabelled sample.
+func writeCounter(w io.Writer, name, help string, v uint64) error {
+	if err := writeHeader(w, name, help, "counter"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %d\n", name, v)
+	return err
+}
+
+// writeGauge writes a gauge family with a single unlabelled sample.
+func writeGauge(w io.Writer, name, help string, v float64) error {
+	if err := writeHeader(w, name, help, "gauge"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+	return err
+}
+
+// writeHistogram writes a histogram family: cumulative buckets, sum and count.
+func writeHistogram(w io.Writer, name, help string, h *Histogram) error {
+	if err := writeHeader(w, name, help, "histogram"); err != nil {
+		return err
+	}
+	var cumulative uint64
+	for i := range h.counts {
+		cumulative += h.counts[i].Load()
+		le := math.Inf(1)
+		if i < len(h.bounds) {
+			le = h.bounds[i]
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(le), cumulative); err != nil {
+			return err
+		}
+	}
+	// Report the count as the sum of the buckets so that it matches +Inf.
+	_, err := fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", name, formatFloat(h.Sum()), name, cumulative)
+	return err
+}
+
+// formatFloat formats a sample value the way Prometheus expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
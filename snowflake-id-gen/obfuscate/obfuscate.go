@@ -0,0 +1,278 @@
+// Package obfuscate maps Snowflake IDs to opaque IDs and back, so that IDs
+// exposed to customers do not reveal when they were created, by which
+// machine, or how many IDs were issued around them.
+//
+// A Cipher is a keyed permutation of the 63-bit non-negative integers: a
+// balanced Feistel network over 64 bits with AES as the round function,
+// cycle-walked to stay below 2^63. Every ID maps to exactly one opaque ID
+// and back, and without the key the opaque IDs look random.
+//
+// A Keyring holds several versioned keys for rotation. It encodes opaque IDs
+// as tokens that start with the version of the key used, so tokens issued
+// under an old key can still be revealed after a new key became current.
+//
+// Obfuscation is not encryption in the strict sense: the permutation is
+// deterministic, so equal IDs map to equal tokens.
+package obfuscate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// rounds is the number of Feistel rounds; four are enough for a secure
+// permutation with a pseudorandom round function, eight leave a margin.
+const rounds = 8
+
+// MaxVersion is the largest key version a Keyring accepts. Versions are
+// encoded as a single base62 character.
+const MaxVersion = len(versionAlphabet) - 1
+
+// TokenLength is the length of the tokens created by a Keyring.
+const TokenLength = 12
+
+// versionAlphabet encodes key versions in tokens.
+const versionAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Errors reported by the package.
+var (
+	// ErrInvalidKey is returned for keys that are not 16, 24 or 32 bytes long.
+	ErrInvalidKey = errors.New("invalid obfuscation key")
+	// ErrInvalidToken is returned for tokens that were not produced by a Keyring.
+	ErrInvalidToken = errors.New("invalid obfuscated ID")
+	// ErrUnknownKeyVersion is returned for tokens whose key is not in the keyring.
+	ErrUnknownKeyVersion = errors.New("unknown key version")
+)
+
+// Cipher is a keyed, reversible permutation of the non-negative int64 values.
+// It is safe for concurrent use.
+type Cipher struct {
+	// The round function.
+	block cipher.Block
+}
+
+// NewCipher returns a Cipher for a 16, 24 or 32 byte key.
+func NewCipher(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d bytes (want 16, 24 or 32)", ErrInvalidKey, len(key))
+	}
+	return &Cipher{block: block}, nil
+}
+
+// Obfuscate maps a non-negative ID to its opaque counterpart, which is also
+// non-negative.
+func (c *Cipher) Obfuscate(id int64) (int64, error) {
+	if id < 0 {
+		return 0, fmt.Errorf("%w: %d is negative", snowflake.ErrInvalidID, id)
+	}
+	// The Feistel network permutes all 64-bit values; cycle-walk until the
+	// result fits into 63 bits again. Every value lies on a cycle, so this
+	// ends, after two rounds on average.
+	x := uint64(id)
+	for {
+		x = c.encrypt(x)
+		if x <= math.MaxInt64 {
+			return int64(x), nil
+		}
+	}
+}
+
+// Reveal maps an opaque ID back to the ID it was obfuscated from.
+func (c *Cipher) Reveal(opaque int64) (int64, error) {
+	if opaque < 0 {
+		return 0, fmt.Errorf("%w: %d is negative", ErrInvalidToken, opaque)
+	}
+	x := uint64(opaque)
+	for {
+		x = c.decrypt(x)
+		if x <= math.MaxInt64 {
+			return int64(x), nil
+		}
+	}
+}
+
+// encrypt runs the Feistel network forwards over 64 bits.
+func (c *Cipher) encrypt(x uint64) uint64 {
+	left, right := uint32(x>>32), uint32(x)
+	for round := range rounds {
+		left, right = right, left^c.round(round, right)
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+// decrypt runs the Feistel network backwards, undoing encrypt.
+func (c *Cipher) decrypt(x uint64) uint64 {
+	left, right := uint32(x>>32), uint32(x)
+	for round := rounds - 1; round >= 0; round-- {
+		left, right = right^c.round(round, left), left
+	}
+	return uint64(left)<<32 | uint64(right)
+}
+
+// round is the round function: the first 32 bits of the AES encryption of
+// the round number and the half block.
+func (c *Cipher) round(round int, half uint32) uint32 {
+	var in, out [aes.BlockSize]byte
+	in[0] = byte(round)
+	binary.BigEndian.PutUint32(in[1:], half)
+	c.block.Encrypt(out[:], in[:])
+	return binary.BigEndian.Uint32(out[:])
+}
+
+// Keyring holds versioned keys. New IDs are obfuscated with the current key,
+// the one with the highest version; tokens of every key in the ring can be
+// revealed. To rotate, add a key with a higher version, and remove the old
+// key once its tokens no longer need to be revealed. Obfuscate and Reveal
+// are safe for concurrent use; Add and Remove are not.
+type Keyring struct {
+	// Ciphers by key version.
+	ciphers map[int]*Cipher
+	// The highest version in the ring, or -1 if it is empty.
+	current int
+}
+
+// NewKeyring returns an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{ciphers: make(map[int]*Cipher), current: -1}
+}
+
+// ParseKeyring parses keys written as version:hex-key, separated by commas
+// or newlines. Blank lines and lines starting with # are ignored, so a key
+// file can be read directly.
+func ParseKeyring(spec string) (*Keyring, error) {
+	k := NewKeyring()
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+		versionText, keyText, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not version:hex-key", ErrInvalidKey, redact(field))
+		}
+		version, err := strconv.Atoi(strings.TrimSpace(versionText))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid version %q", ErrInvalidKey, versionText)
+		}
+		key, err := hex.DecodeString(strings.TrimSpace(keyText))
+		if err != nil {
+			return nil, fmt.Errorf("%w: key version %d is not hexadecimal", ErrInvalidKey, version)
+		}
+		if err := k.Add(version, key); err != nil {
+			return nil, err
+		}
+	}
+	if k.current < 0 {
+		return nil, fmt.Errorf("%w: no keys", ErrInvalidKey)
+	}
+	return k, nil
+}
+
+// redact hides the key part of a malformed key specification.
+func redact(field string) string {
+	if len(field) > 4 {
+		return field[:4] + "..."
+	}
+	return field
+}
+
+// Add puts a key into the ring under a version between 0 and MaxVersion.
+// A version can only be added once.
+func (k *Keyring) Add(version int, key []byte) error {
+	if version < 0 || version > MaxVersion {
+		return fmt.Errorf("%w: version %d is not between 0 and %d", ErrInvalidKey, version, MaxVersion)
+	}
+	if _, ok := k.ciphers[version]; ok {
+		return fmt.Errorf("%w: version %d is already in the keyring", ErrInvalidKey, version)
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("key version %d: %w", version, err)
+	}
+	k.ciphers[version] = c
+	k.current = max(k.current, version)
+	return nil
+}
+
+// Remove drops a key version from the ring; its tokens can no longer be
+// revealed.
+func (k *Keyring) Remove(version int) {
+	delete(k.ciphers, version)
+	k.current = -1
+	for v := range k.ciphers {
+		k.current = max(k.current, v)
+	}
+}
+
+// Current returns the version new tokens are created with, or -1 if the
+// ring is empty.
+func (k *Keyring) Current() int {
+	return k.current
+}
+
+// Versions returns the key versions in the ring, in ascending order.
+func (k *Keyring) Versions() []int {
+	versions := make([]int, 0, len(k.ciphers))
+	for v := range k.ciphers {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Obfuscate returns the token of an ID under the current key: the key
+// version as one base62 character followed by the 11 character base62
+// encoding of the opaque ID.
+func (k *Keyring) Obfuscate(id int64) (string, error) {
+	c, ok := k.ciphers[k.current]
+	if !ok {
+		return "", fmt.Errorf("%w: the keyring is empty", ErrUnknownKeyVersion)
+	}
+	opaque, err := c.Obfuscate(id)
+	if err != nil {
+		return "", err
+	}
+	return string(versionAlphabet[k.current]) + snowflake.ID(opaque).Base62(), nil
+}
+
+// Reveal returns the ID a token was created from, using the key version
+// named by the token.
+func (k *Keyring) Reveal(token string) (int64, error) {
+	version, err := TokenVersion(token)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := k.ciphers[version]
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, version)
+	}
+	opaque, err := snowflake.ParseBase62(token[1:])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidToken, token)
+	}
+	return c.Reveal(opaque.Int64())
+}
+
+// TokenVersion returns the key version a token was created with.
+func TokenVersion(token string) (int, error) {
+	if len(token) != TokenLength {
+		return 0, fmt.Errorf("%w: %q is not %d characters long", ErrInvalidToken, token, TokenLength)
+	}
+	version := strings.IndexByte(versionAlphabet, token[0])
+	if version < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidToken, token)
+	}
+	return version, nil
+}
@@ -0,0 +1,168 @@
+package obfuscate
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Test keys, one per version.
+var (
+	key1 = bytes.Repeat([]byte{0x11}, 16)
+	key2 = bytes.Repeat([]byte{0x22}, 32)
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := NewCipher(key1)
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+	// Find an ID whose first pass through the network leaves 63 bits, so
+	// that cycle walking is exercised as well.
+	walked := int64(-1)
+	for id := int64(2); walked < 0; id++ {
+		if c.encrypt(uint64(id)) > math.MaxInt64 {
+			walked = id
+		}
+	}
+
+	tests := []struct {
+		name string
+		id   int64
+	}{
+		{"zero", 0},
+		{"one", 1},
+		{"max", math.MaxInt64},
+		{"cycle walked", walked},
+		{"snowflake", 370027206400839680},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opaque, err := c.Obfuscate(tt.id)
+			if err != nil {
+				t.Fatalf("Obfuscate(%d): %v", tt.id, err)
+			}
+			if opaque < 0 {
+				t.Errorf("Obfuscate(%d) = %d, want a non-negative ID", tt.id, opaque)
+			}
+			if opaque == tt.id {
+				t.Errorf("Obfuscate(%d) returned the ID unchanged", tt.id)
+			}
+			got, err := c.Reveal(opaque)
+			if err != nil {
+				t.Fatalf("Reveal(%d): %v", opaque, err)
+			}
+			if got != tt.id {
+				t.Errorf("Reveal(Obfuscate(%d)) = %d", tt.id, got)
+			}
+		})
+	}
+
+	if _, err := c.Obfuscate(-1); err == nil {
+		t.Error("Obfuscate(-1) succeeded, want an error")
+	}
+	if _, err := c.Reveal(-1); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Reveal(-1): got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	k := NewKeyring()
+	if err := k.Add(1, key1); err != nil {
+		t.Fatalf("Add(1): %v", err)
+	}
+	const id = 370027206400839680
+	oldToken, err := k.Obfuscate(id)
+	if err != nil {
+		t.Fatalf("Obfuscate: %v", err)
+	}
+	if len(oldToken) != TokenLength || oldToken[0] != '1' {
+		t.Fatalf("token %q is not %d characters starting with version 1", oldToken, TokenLength)
+	}
+
+	// A new key becomes current; old tokens still reveal.
+	if err := k.Add(2, key2); err != nil {
+		t.Fatalf("Add(2): %v", err)
+	}
+	if k.Current() != 2 {
+		t.Errorf("Current = %d, want 2", k.Current())
+	}
+	newToken, err := k.Obfuscate(id)
+	if err != nil {
+		t.Fatalf("Obfuscate with the new key: %v", err)
+	}
+	if newToken[0] != '2' || newToken == oldToken {
+		t.Errorf("token under the new key %q, old token %q", newToken, oldToken)
+	}
+	for _, token := range []string{oldToken, newToken} {
+		if got, err := k.Reveal(token); err != nil || got != id {
+			t.Errorf("Reveal(%q) = %d, %v, want %d", token, got, err, id)
+		}
+	}
+
+	// Once the old key is removed, its tokens no longer reveal.
+	k.Remove(1)
+	if _, err := k.Reveal(oldToken); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Reveal of a removed key's token: got %v, want ErrUnknownKeyVersion", err)
+	}
+	if got, err := k.Reveal(newToken); err != nil || got != id {
+		t.Errorf("Reveal(%q) after removing the old key = %d, %v, want %d", newToken, got, err, id)
+	}
+
+	k.Remove(2)
+	if _, err := k.Obfuscate(id); !errors.Is(err, ErrUnknownKeyVersion) {
+		t.Errorf("Obfuscate with an empty keyring: got %v, want ErrUnknownKeyVersion", err)
+	}
+}
+
+func TestKeyringRevealInvalidToken(t *testing.T) {
+	k := NewKeyring()
+	if err := k.Add(1, key1); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	for _, token := range []string{"", "1abc", "1abcdefghijkl", "-abcdefghijk", "1abcdefghij!"} {
+		if _, err := k.Reveal(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Reveal(%q): got %v, want ErrInvalidToken", token, err)
+		}
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	hex1 := strings.Repeat("11", 16)
+	hex2 := strings.Repeat("22", 32)
+
+	k, err := ParseKeyring("# rotated 2025-06\n1:" + hex1 + "\n\n2:" + hex2 + "\n")
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	if got := k.Versions(); len(got) != 2 || got[0] != 1 || got[1] != 2 || k.Current() != 2 {
+		t.Errorf("Versions = %v, Current = %d, want [1 2] and 2", got, k.Current())
+	}
+
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"bad hex", "1:" + strings.Repeat("zz", 16)},
+		{"wrong key length", "1:" + strings.Repeat("11", 15)},
+		{"duplicate version", "1:" + hex1 + ",1:" + hex2},
+		{"version out of range", "62:" + hex1},
+		{"bad version", "v1:" + hex1},
+		{"missing key", hex1},
+		{"no keys", "# nothing here\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeyring(tt.spec)
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Fatalf("ParseKeyring(%q): got %v, want ErrInvalidKey", tt.spec, err)
+			}
+			// Errors must not echo key material.
+			if strings.Contains(err.Error(), hex1[:8]) || strings.Contains(err.Error(), hex2[:8]) {
+				t.Errorf("error %q leaks the key", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package snowflake
+
+import "time"
+
+// Observer receives instrumentation events from a generator, for example to
+// export metrics (see package metrics). Methods may be called concurrently,
+// some of them with the generator's lock held, so they must be cheap and must
+// not call back into the generator.
+type Observer interface {
+	// ObserveIDs is called after n IDs have been issued.
+	ObserveIDs(n int)
+	// ObserveSequenceOverflow is called when a caller finds the sequence of
+	// the current tick exhausted and the generator has to move on to the next
+	// tick (or, for TryGenerateID, give up).
+	ObserveSequenceOverflow()
+	// ObserveTickWait is called with the time spent blocked waiting for the
+	// next tick after a sequence overflow.
+	ObserveTickWait(d time.Duration)
+	// ObserveRollback is called for every detected clock regression, after
+	// the hook registered with WithRollbackHook.
+	ObserveRollback(e RollbackEvent)
+}
+
+// WithObserver makes the generator report instrumentation events to o.
+func WithObserver(o Observer) Option {
+	return func(s *Snowflake) {
+		s.observer = o
+	}
+}
+
+// Lag returns how far the clock is ahead of the timestamp of the last issued
+// ID. It grows while the generator is idle and is negative while
+// RollbackBorrow issues IDs ahead of the clock. It is 0 before the first ID.
+func (s *Snowflake) Lag() time.Duration {
+	s.mu.Lock()
+	last := s.lastTimestamp
+	s.mu.Unlock()
+	if last < 0 {
+		return 0
+	}
+	return s.clock.Now().Sub(s.base.time(last))
+}
+
+// reportRollbacks passes clock regression events to the rollback hook and the
+// observer. It must be called without the lock held.
+func (s *Snowflake) reportRollbacks(events ...RollbackEvent) {
+	for _, event := range events {
+		if s.onRollback != nil {
+			s.onRollback(event)
+		}
+		if s.observer != nil {
+			s.observer.ObserveRollback(event)
+		}
+	}
+}
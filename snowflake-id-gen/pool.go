@@ -0,0 +1,220 @@
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInvalidPool is returned when a Pool cannot be built from its configuration.
+var ErrInvalidPool = errors.New("invalid pool")
+
+// PoolRouting selects how a Pool spreads callers over its shards.
+type PoolRouting int
+
+const (
+	// RouteRoundRobin sends consecutive calls to consecutive shards. This is
+	// the default.
+	RouteRoundRobin PoolRouting = iota
+	// RouteAffinity keeps callers on the same shard for as long as possible.
+	// Go has no goroutine IDs, so affinity is per processor (P): goroutines
+	// running on the same P share a shard, which keeps each shard's mutex
+	// mostly uncontended.
+	RouteAffinity
+)
+
+// poolRoutingNames maps routings to the names used by String and
+// ParsePoolRouting.
+var poolRoutingNames = map[PoolRouting]string{
+	RouteRoundRobin: "round-robin",
+	RouteAffinity:   "affinity",
+}
+
+// String returns the name of the routing.
+func (r PoolRouting) String() string {
+	if name, ok := poolRoutingNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("PoolRouting(%d)", int(r))
+}
+
+// ParsePoolRouting parses "round-robin" or "affinity".
+func ParsePoolRouting(s string) (PoolRouting, error) {
+	for routing, name := range poolRoutingNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return routing, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown pool routing %q (want round-robin or affinity)", s)
+}
+
+// Pool multiplies the throughput of a single generator by running one
+// Snowflake per shard, each with its own machine ID from a contiguous range.
+// Once a shard's sequence is exhausted only that shard waits for the next
+// tick.
+//
+// IDs from a pool are unique and each shard's IDs are strictly increasing,
+// but IDs from different shards issued within the same tick do not sort by
+// issue order.
+type Pool struct {
+	// One generator per shard; shard i uses machine ID firstMachineID+i.
+	shards []*Snowflake
+	// IDs issued by each shard.
+	issued []atomic.Uint64
+	// How callers are routed to shards.
+	routing PoolRouting
+	// The next shard for RouteRoundRobin and for new RouteAffinity slots.
+	next atomic.Uint64
+	// Per-P shard indexes for RouteAffinity.
+	affinity sync.Pool
+	// When the pool was created, for throughput stats.
+	created time.Time
+}
+
+// NewPool creates a pool of size shards using the machine IDs firstMachineID
+// to firstMachineID+size-1. The options are applied to every shard. Options
+// that cannot be shared between machine IDs (WithLease, WithCheckpoint and
+// WithSpareMachineIDs) are rejected.
+func NewPool(firstMachineID int64, size int, routing PoolRouting, opts ...Option) (*Pool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("%w: %d shards", ErrInvalidPool, size)
+	}
+	if _, ok := poolRoutingNames[routing]; !ok {
+		return nil, fmt.Errorf("%w: unknown routing %v", ErrInvalidPool, routing)
+	}
+
+	p := &Pool{
+		shards:  make([]*Snowflake, size),
+		issued:  make([]atomic.Uint64, size),
+		routing: routing,
+		created: time.Now(),
+	}
+	for i := range p.shards {
+		s, err := NewSnowflake(firstMachineID+int64(i), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		// Every shard would claim the same lease, checkpoint or spare IDs.
+		if s.lease != nil || s.checkpoint != nil || len(s.spareMachineIDs) > 0 {
+			return nil, fmt.Errorf("%w: leases, checkpoints and spare machine IDs cannot be shared by shards", ErrInvalidPool)
+		}
+		p.shards[i] = s
+	}
+	// Hand each P that asks for a slot the next shard in turn.
+	p.affinity.New = func() any {
+		shard := int(p.next.Add(1)-1) % len(p.shards)
+		return &shard
+	}
+	return p, nil
+}
+
+// Size returns the number of shards.
+func (p *Pool) Size() int {
+	return len(p.shards)
+}
+
+// Layout returns the bit layout and epoch shared by all shards.
+func (p *Pool) Layout() Layout {
+	return p.shards[0].Layout()
+}
+
+// MachineIDs returns the machine ID of every shard, in shard order.
+func (p *Pool) MachineIDs() []int64 {
+	ids := make([]int64, len(p.shards))
+	for i, s := range p.shards {
+		ids[i] = s.MachineID()
+	}
+	return ids
+}
+
+// Decode splits an ID generated by the pool into its components.
+func (p *Pool) Decode(id int64) (Parts, error) {
+	return p.Layout().Decode(id)
+}
+
+// GenerateID creates and returns a new unique ID from one of the shards.
+func (p *Pool) GenerateID() (int64, error) {
+	return p.generate(context.Background(), true)
+}
+
+// GenerateIDContext is like GenerateID but gives up with ctx's error
+// (wrapped) once ctx is done while the chosen shard waits for the clock.
+func (p *Pool) GenerateIDContext(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return p.generate(ctx, true)
+}
+
+// TryGenerateID is like GenerateID but returns ErrSequenceExhausted instead
+// of waiting when the chosen shard's tick is used up. It does not try the
+// other shards.
+func (p *Pool) TryGenerateID() (int64, error) {
+	return p.generate(context.Background(), false)
+}
+
+// generate routes the call to a shard.
+func (p *Pool) generate(ctx context.Context, wait bool) (int64, error) {
+	switch p.routing {
+	case RouteAffinity:
+		// Borrow this P's slot for the duration of the call.
+		slot := p.affinity.Get().(*int)
+		id, err := p.generateOn(ctx, wait, *slot)
+		p.affinity.Put(slot)
+		return id, err
+	default:
+		return p.generateOn(ctx, wait, int((p.next.Add(1)-1)%uint64(len(p.shards))))
+	}
+}
+
+// generateOn issues an ID from shard i and counts it.
+func (p *Pool) generateOn(ctx context.Context, wait bool, i int) (int64, error) {
+	id, err := p.shards[i].generate(ctx, wait)
+	if err != nil {
+		return 0, err
+	}
+	p.issued[i].Add(1)
+	return id, nil
+}
+
+// PoolStats reports the combined throughput of a pool.
+type PoolStats struct {
+	// IDs issued by all shards.
+	Issued uint64
+	// IDs issued by each shard, in shard order.
+	PerShard []uint64
+	// Time since the pool was created.
+	Elapsed time.Duration
+	// Issued divided by Elapsed, in IDs per second.
+	IDsPerSecond float64
+	// Clock regressions seen by all shards.
+	Rollbacks RollbackStats
+}
+
+// Stats returns the combined throughput stats of the pool.
+func (p *Pool) Stats() PoolStats {
+	stats := PoolStats{
+		PerShard: make([]uint64, len(p.shards)),
+		Elapsed:  time.Since(p.created),
+	}
+	for i, s := range p.shards {
+		stats.PerShard[i] = p.issued[i].Load()
+		stats.Issued += stats.PerShard[i]
+
+		// Sum the rollback counters of all shards.
+		r := s.RollbackStats()
+		stats.Rollbacks.Events += r.Events
+		stats.Rollbacks.Waits += r.Waits
+		stats.Rollbacks.Borrows += r.Borrows
+		stats.Rollbacks.Switches += r.Switches
+		stats.Rollbacks.Failures += r.Failures
+	}
+	if stats.Elapsed > 0 {
+		stats.IDsPerSecond = float64(stats.Issued) / stats.Elapsed.Seconds()
+	}
+	return stats
+}
@@ -0,0 +1,205 @@
+package snowflake
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newManualPool returns a pool of size shards starting at machine ID 10,
+// reading a ManualClock set to testStart.
+func newManualPool(t *testing.T, size int, routing PoolRouting, opts ...Option) (*Pool, *ManualClock) {
+	t.Helper()
+	clock := NewManualClock(testStart)
+	p, err := NewPool(10, size, routing, append([]Option{WithClock(clock)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+	return p, clock
+}
+
+// machineIDOf returns the machine ID of an ID issued by p.
+func machineIDOf(t *testing.T, p *Pool, id int64) int64 {
+	t.Helper()
+	parts, err := p.Decode(id)
+	if err != nil {
+		t.Fatalf("Decode(%d): %v", id, err)
+	}
+	return parts.MachineID
+}
+
+func TestPoolMachineIDs(t *testing.T) {
+	p, _ := newManualPool(t, 4, RouteRoundRobin)
+	if p.Size() != 4 {
+		t.Errorf("Size = %d, want 4", p.Size())
+	}
+	got := p.MachineIDs()
+	want := []int64{10, 11, 12, 13}
+	if len(got) != len(want) {
+		t.Fatalf("MachineIDs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("MachineIDs = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	p, _ := newManualPool(t, 4, RouteRoundRobin)
+	for i := range 12 {
+		id, err := p.GenerateID()
+		if err != nil {
+			t.Fatalf("GenerateID %d: %v", i, err)
+		}
+		if got, want := machineIDOf(t, p, id), int64(10+i%4); got != want {
+			t.Errorf("call %d went to machine ID %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestPoolAffinity(t *testing.T) {
+	p, _ := newManualPool(t, 4, RouteAffinity)
+	// Sequential calls from one goroutine mostly stay on the same shard.
+	// The runtime may drop pooled slots at any time, moving the caller to
+	// the next shard, so only require far fewer moves than round-robin.
+	const calls = 1000
+	var moves int
+	last := int64(-1)
+	for i := range calls {
+		id, err := p.GenerateID()
+		if err != nil {
+			t.Fatalf("GenerateID %d: %v", i, err)
+		}
+		machineID := machineIDOf(t, p, id)
+		if machineID < 10 || machineID > 13 {
+			t.Fatalf("ID from machine ID %d, outside the pool", machineID)
+		}
+		if last >= 0 && machineID != last {
+			moves++
+		}
+		last = machineID
+	}
+	if moves > calls/2 {
+		t.Errorf("%d of %d calls moved to another shard", moves, calls)
+	}
+}
+
+func TestPoolConcurrentUnique(t *testing.T) {
+	for _, routing := range []PoolRouting{RouteRoundRobin, RouteAffinity} {
+		t.Run(routing.String(), func(t *testing.T) {
+			p, _ := newManualPool(t, 4, routing)
+			const goroutines, perGoroutine = 32, 500
+			ids := make([][]int64, goroutines)
+			var wg sync.WaitGroup
+			for g := range goroutines {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range perGoroutine {
+						id, err := p.GenerateID()
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						ids[g] = append(ids[g], id)
+					}
+				}()
+			}
+			wg.Wait()
+
+			seen := make(map[int64]bool, goroutines*perGoroutine)
+			for _, got := range ids {
+				for _, id := range got {
+					if seen[id] {
+						t.Fatalf("ID %d issued twice", id)
+					}
+					seen[id] = true
+				}
+			}
+			if stats := p.Stats(); stats.Issued != goroutines*perGoroutine {
+				t.Errorf("Stats.Issued = %d, want %d", stats.Issued, goroutines*perGoroutine)
+			}
+		})
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	p, clock := newManualPool(t, 3, RouteRoundRobin)
+	for range 9 {
+		if _, err := p.GenerateID(); err != nil {
+			t.Fatalf("GenerateID: %v", err)
+		}
+	}
+	// Every shard sees the clock move backwards once.
+	clock.Advance(-time.Millisecond)
+	for range 3 {
+		if _, err := p.GenerateID(); !errors.Is(err, ErrClockMovedBackwards) {
+			t.Fatalf("GenerateID after a backwards jump: got %v, want ErrClockMovedBackwards", err)
+		}
+	}
+
+	stats := p.Stats()
+	if stats.Issued != 9 {
+		t.Errorf("Issued = %d, want 9", stats.Issued)
+	}
+	for i, n := range stats.PerShard {
+		if n != 3 {
+			t.Errorf("shard %d issued %d IDs, want 3", i, n)
+		}
+	}
+	if stats.Rollbacks.Events != 3 || stats.Rollbacks.Failures != 3 {
+		t.Errorf("Rollbacks = %+v, want 3 failed events", stats.Rollbacks)
+	}
+	if stats.Elapsed <= 0 || stats.IDsPerSecond <= 0 {
+		t.Errorf("Elapsed = %v, IDsPerSecond = %v, want both positive", stats.Elapsed, stats.IDsPerSecond)
+	}
+}
+
+func TestNewPoolRejectsSharedOptions(t *testing.T) {
+	cp := FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint")}
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{"lease", WithLease(testLease{machineID: 10})},
+		{"checkpoint", WithCheckpoint(cp, time.Second)},
+		{"spares", WithSpareMachineIDs(100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPool(10, 1, RouteRoundRobin, tt.opt); !errors.Is(err, ErrInvalidPool) {
+				t.Errorf("NewPool: got %v, want ErrInvalidPool", err)
+			}
+		})
+	}
+}
+
+func TestNewPoolInvalid(t *testing.T) {
+	// The fifth shard would need machine ID 1024.
+	_, err := NewPool(MaxMachineID-3, 5, RouteRoundRobin)
+	if !errors.Is(err, ErrInvalidMachineID) || !strings.Contains(err.Error(), "shard 4") {
+		t.Errorf("NewPool past the last machine ID: got %v, want ErrInvalidMachineID for shard 4", err)
+	}
+	if _, err := NewPool(MaxMachineID-3, 4, RouteRoundRobin); err != nil {
+		t.Errorf("NewPool up to the last machine ID: %v", err)
+	}
+	if _, err := NewPool(0, 0, RouteRoundRobin); !errors.Is(err, ErrInvalidPool) {
+		t.Errorf("NewPool without shards: got %v, want ErrInvalidPool", err)
+	}
+	if _, err := NewPool(0, 1, PoolRouting(9)); !errors.Is(err, ErrInvalidPool) {
+		t.Errorf("NewPool with an unknown routing: got %v, want ErrInvalidPool", err)
+	}
+}
+
+// testLease is a MachineIDLease that is held until err is set.
+type testLease struct {
+	machineID int64
+	err       error
+}
+
+func (l testLease) MachineID() int64 { return l.machineID }
+func (l testLease) Err() error       { return l.err }
@@ -0,0 +1,289 @@
+package snowflake
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrMachineIDNotConfigured is returned by providers whose source is not set
+// up on this host (e.g. an unset environment variable). A ChainProvider
+// moves on to the next provider silently in that case.
+var ErrMachineIDNotConfigured = errors.New("machine ID not configured")
+
+// DefaultMachineIDEnv is the environment variable read by EnvProvider when
+// no variable name is given.
+const DefaultMachineIDEnv = "SNOWFLAKE_MACHINE_ID"
+
+// MachineIDProvider determines the machine ID of this host for a layout.
+type MachineIDProvider interface {
+	// Name identifies the provider in logs and flags.
+	Name() string
+	// MachineID returns a machine ID between 0 and l.MaxMachineID().
+	MachineID(l Layout) (int64, error)
+}
+
+// ExplicitProvider returns a fixed machine ID. A negative ID means "not
+// configured".
+type ExplicitProvider struct {
+	ID int64
+}
+
+// Name implements MachineIDProvider.
+func (p ExplicitProvider) Name() string { return "explicit" }
+
+// MachineID implements MachineIDProvider.
+func (p ExplicitProvider) MachineID(l Layout) (int64, error) {
+	if p.ID < 0 {
+		return 0, ErrMachineIDNotConfigured
+	}
+	return checkMachineID(p.ID, l)
+}
+
+// EnvProvider reads a decimal machine ID from an environment variable
+// (DefaultMachineIDEnv if Var is empty).
+type EnvProvider struct {
+	Var string
+}
+
+// Name implements MachineIDProvider.
+func (p EnvProvider) Name() string { return "env" }
+
+// MachineID implements MachineIDProvider.
+func (p EnvProvider) MachineID(l Layout) (int64, error) {
+	name := p.Var
+	if name == "" {
+		name = DefaultMachineIDEnv
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok || strings.TrimSpace(value) == "" {
+		return 0, fmt.Errorf("%w: $%s is not set", ErrMachineIDNotConfigured, name)
+	}
+	return parseMachineID(value, "$"+name, l)
+}
+
+// hostnameOrdinalPattern matches the trailing ordinal of hostnames such as
+// "idgen-7", as assigned to the pods of a Kubernetes StatefulSet.
+var hostnameOrdinalPattern = regexp.MustCompile(`-(\d+)$`)
+
+// HostnameOrdinalProvider uses the numeric suffix of the hostname (e.g. 7 for
+// "idgen-7") as the machine ID. Hostname defaults to os.Hostname().
+type HostnameOrdinalProvider struct {
+	Hostname string
+}
+
+// Name implements MachineIDProvider.
+func (p HostnameOrdinalProvider) Name() string { return "ordinal" }
+
+// MachineID implements MachineIDProvider.
+func (p HostnameOrdinalProvider) MachineID(l Layout) (int64, error) {
+	hostname, err := hostnameOr(p.Hostname)
+	if err != nil {
+		return 0, err
+	}
+	// Only the first label matters for fully qualified names.
+	short, _, _ := strings.Cut(hostname, ".")
+	match := hostnameOrdinalPattern.FindStringSubmatch(short)
+	if match == nil {
+		return 0, fmt.Errorf("%w: hostname %q has no numeric ordinal suffix", ErrMachineIDNotConfigured, hostname)
+	}
+	return parseMachineID(match[1], "hostname "+hostname, l)
+}
+
+// HostnameHashProvider hashes the hostname (FNV-1a) into the machine ID bits.
+// Distinct hosts may collide; it is a fallback, not a uniqueness guarantee.
+// Hostname defaults to os.Hostname().
+type HostnameHashProvider struct {
+	Hostname string
+}
+
+// Name implements MachineIDProvider.
+func (p HostnameHashProvider) Name() string { return "hash" }
+
+// MachineID implements MachineIDProvider.
+func (p HostnameHashProvider) MachineID(l Layout) (int64, error) {
+	hostname, err := hostnameOr(p.Hostname)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write([]byte(hostname))
+	return int64(h.Sum64() & uint64(l.MaxMachineID())), nil
+}
+
+// PrivateIPv4Provider uses the low bits of the first private (RFC 1918)
+// IPv4 address of an interface that is up. With a 10-bit machine ID this
+// is unique within any /22 subnet.
+type PrivateIPv4Provider struct{}
+
+// Name implements MachineIDProvider.
+func (PrivateIPv4Provider) Name() string { return "ip" }
+
+// MachineID implements MachineIDProvider.
+func (PrivateIPv4Provider) MachineID(l Layout) (int64, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	for _, iface := range interfaces {
+		// Skip loopback interfaces and interfaces that are down.
+		if iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := ipNet.IP.To4()
+			if ip == nil || !ip.IsPrivate() {
+				continue
+			}
+			// Combine the four bytes and keep the low machine ID bits.
+			raw := int64(ip[0])<<24 | int64(ip[1])<<16 | int64(ip[2])<<8 | int64(ip[3])
+			return raw & l.MaxMachineID(), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: no private IPv4 address found", ErrMachineIDNotConfigured)
+}
+
+// FileProvider reads a decimal machine ID from a local file, e.g. one
+// written by provisioning tooling.
+type FileProvider struct {
+	Path string
+}
+
+// Name implements MachineIDProvider.
+func (p FileProvider) Name() string { return "file" }
+
+// MachineID implements MachineIDProvider.
+func (p FileProvider) MachineID(l Layout) (int64, error) {
+	if p.Path == "" {
+		return 0, fmt.Errorf("%w: no machine ID file given", ErrMachineIDNotConfigured)
+	}
+	data, err := os.ReadFile(p.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("%w: %s does not exist", ErrMachineIDNotConfigured, p.Path)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read machine ID file: %w", err)
+	}
+	return parseMachineID(string(data), p.Path, l)
+}
+
+// MACProvider derives the machine ID from the MAC address, as MachineIDFromMACForLayout does.
+type MACProvider struct{}
+
+// Name implements MachineIDProvider.
+func (MACProvider) Name() string { return "mac" }
+
+// MachineID implements MachineIDProvider.
+func (MACProvider) MachineID(l Layout) (int64, error) {
+	return MachineIDFromMACForLayout(l)
+}
+
+// ChainProvider tries its providers in order and returns the first machine
+// ID found. Failures are logged and the next provider is tried.
+type ChainProvider []MachineIDProvider
+
+// Name implements MachineIDProvider.
+func (c ChainProvider) Name() string {
+	names := make([]string, len(c))
+	for i, p := range c {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// MachineID implements MachineIDProvider.
+func (c ChainProvider) MachineID(l Layout) (int64, error) {
+	id, _, err := c.Resolve(l)
+	return id, err
+}
+
+// Resolve is like MachineID but also returns the name of the provider that won.
+func (c ChainProvider) Resolve(l Layout) (int64, string, error) {
+	var errs []error
+	for _, p := range c {
+		id, err := p.MachineID(l)
+		if err == nil {
+			log.Printf("Using machine ID %d from provider %s", id, p.Name())
+			return id, p.Name(), nil
+		}
+		// Unconfigured sources are expected; real failures are worth a log line.
+		if !errors.Is(err, ErrMachineIDNotConfigured) {
+			log.Printf("Machine ID provider %s failed: %v", p.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return 0, "", fmt.Errorf("no machine ID provider succeeded: %w", errors.Join(errs...))
+}
+
+// ParseProviders builds a ChainProvider from a comma separated list of
+// provider names: explicit, env, ordinal, hash, ip, file and mac. The
+// configurable providers (explicit, env and file) are passed in ready-made.
+func ParseProviders(spec string, explicit ExplicitProvider, env EnvProvider, file FileProvider) (ChainProvider, error) {
+	var chain ChainProvider
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(name) {
+		case "explicit":
+			chain = append(chain, explicit)
+		case "env":
+			chain = append(chain, env)
+		case "ordinal":
+			chain = append(chain, HostnameOrdinalProvider{})
+		case "hash":
+			chain = append(chain, HostnameHashProvider{})
+		case "ip":
+			chain = append(chain, PrivateIPv4Provider{})
+		case "file":
+			chain = append(chain, file)
+		case "mac":
+			chain = append(chain, MACProvider{})
+		default:
+			return nil, fmt.Errorf("unknown machine ID provider %q (want explicit, env, ordinal, hash, ip, file or mac)", name)
+		}
+	}
+	return chain, nil
+}
+
+// parseMachineID parses a decimal machine ID read from source and checks it
+// against the layout.
+func parseMachineID(value, source string, l Layout) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s does not hold a number: %q", ErrInvalidMachineID, source, strings.TrimSpace(value))
+	}
+	return checkMachineID(id, l)
+}
+
+// checkMachineID checks that id fits the layout. Unlike the hashing
+// providers, configured IDs are never masked: a too large value is an error.
+func checkMachineID(id int64, l Layout) (int64, error) {
+	if id < 0 || id > l.MaxMachineID() {
+		return 0, fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidMachineID, id, l.MaxMachineID())
+	}
+	return id, nil
+}
+
+// hostnameOr returns hostname, or the system hostname if it is empty.
+func hostnameOr(hostname string) (string, error) {
+	if hostname != "" {
+		return hostname, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to get hostname: %w", err)
+	}
+	return hostname, nil
+}
@@ -0,0 +1,148 @@
+package snowflake
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// smallLayout has machine IDs 0 to 15.
+var smallLayout = Layout{TimestampBits: 47, WorkerBits: 4, SequenceBits: 12, Epoch: DefaultEpoch}
+
+func TestProviders(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	t.Setenv("TEST_MACHINE_ID", " 12\n")
+	t.Setenv("TEST_MACHINE_ID_BIG", "16")
+	t.Setenv("TEST_MACHINE_ID_BAD", "twelve")
+	t.Setenv("TEST_MACHINE_ID_EMPTY", "")
+
+	tests := []struct {
+		name     string
+		provider MachineIDProvider
+		want     int64
+		// The error the provider must fail with, if any.
+		wantErr error
+	}{
+		{"explicit", ExplicitProvider{ID: 15}, 15, nil},
+		{"explicit unset", ExplicitProvider{ID: -1}, 0, ErrMachineIDNotConfigured},
+		{"explicit out of range", ExplicitProvider{ID: 16}, 0, ErrInvalidMachineID},
+		{"env", EnvProvider{Var: "TEST_MACHINE_ID"}, 12, nil},
+		{"env unset", EnvProvider{Var: "TEST_MACHINE_ID_UNSET"}, 0, ErrMachineIDNotConfigured},
+		{"env empty", EnvProvider{Var: "TEST_MACHINE_ID_EMPTY"}, 0, ErrMachineIDNotConfigured},
+		{"env out of range", EnvProvider{Var: "TEST_MACHINE_ID_BIG"}, 0, ErrInvalidMachineID},
+		{"env not a number", EnvProvider{Var: "TEST_MACHINE_ID_BAD"}, 0, ErrInvalidMachineID},
+		{"ordinal", HostnameOrdinalProvider{Hostname: "idgen-7"}, 7, nil},
+		{"ordinal fully qualified", HostnameOrdinalProvider{Hostname: "idgen-3.idgen.default.svc"}, 3, nil},
+		{"ordinal missing", HostnameOrdinalProvider{Hostname: "idgen"}, 0, ErrMachineIDNotConfigured},
+		{"ordinal in a later label", HostnameOrdinalProvider{Hostname: "idgen.rack-4"}, 0, ErrMachineIDNotConfigured},
+		{"ordinal out of range", HostnameOrdinalProvider{Hostname: "idgen-16"}, 0, ErrInvalidMachineID},
+		{"file", FileProvider{Path: writeFile("id", "9\n")}, 9, nil},
+		{"file unset", FileProvider{}, 0, ErrMachineIDNotConfigured},
+		{"file missing", FileProvider{Path: filepath.Join(dir, "missing")}, 0, ErrMachineIDNotConfigured},
+		{"file out of range", FileProvider{Path: writeFile("big", "1024")}, 0, ErrInvalidMachineID},
+		{"file not a number", FileProvider{Path: writeFile("bad", "")}, 0, ErrInvalidMachineID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.provider.MachineID(smallLayout)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("MachineID: got %d, %v, want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("MachineID = %d, %v, want %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostnameHashProvider(t *testing.T) {
+	p := HostnameHashProvider{Hostname: "idgen-a.example.com"}
+	id, err := p.MachineID(DefaultLayout)
+	if err != nil {
+		t.Fatalf("MachineID: %v", err)
+	}
+	if again, _ := p.MachineID(DefaultLayout); again != id {
+		t.Errorf("MachineID is not deterministic: %d, then %d", id, again)
+	}
+	// Smaller layouts keep the low bits of the same hash.
+	small, err := p.MachineID(smallLayout)
+	if err != nil {
+		t.Fatalf("MachineID: %v", err)
+	}
+	if small != id&smallLayout.MaxMachineID() {
+		t.Errorf("MachineID for 4 bits = %d, want the low bits %d of %d", small, id&smallLayout.MaxMachineID(), id)
+	}
+	if other, _ := (HostnameHashProvider{Hostname: "idgen-b.example.com"}).MachineID(DefaultLayout); other == id {
+		t.Errorf("two hostnames hash to the same machine ID %d", id)
+	}
+}
+
+func TestChainProvider(t *testing.T) {
+	dir := t.TempDir()
+	badFile := filepath.Join(dir, "bad")
+	if err := os.WriteFile(badFile, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unconfigured and failing providers are skipped alike.
+	chain := ChainProvider{
+		EnvProvider{Var: "TEST_MACHINE_ID_UNSET"},
+		FileProvider{Path: badFile},
+		HostnameOrdinalProvider{Hostname: "idgen-5"},
+		ExplicitProvider{ID: 1},
+	}
+	id, name, err := chain.Resolve(smallLayout)
+	if err != nil || id != 5 || name != "ordinal" {
+		t.Errorf("Resolve = %d, %q, %v, want 5 from ordinal", id, name, err)
+	}
+	if got := chain.Name(); got != "env,file,ordinal,explicit" {
+		t.Errorf("Name = %q", got)
+	}
+
+	// With every provider failing, all errors are reported.
+	chain = ChainProvider{
+		EnvProvider{Var: "TEST_MACHINE_ID_UNSET"},
+		FileProvider{Path: badFile},
+		ExplicitProvider{ID: 99},
+	}
+	_, err = chain.MachineID(smallLayout)
+	if !errors.Is(err, ErrMachineIDNotConfigured) || !errors.Is(err, ErrInvalidMachineID) {
+		t.Fatalf("MachineID with every provider failing: got %v, want both errors", err)
+	}
+	for _, name := range []string{"env:", "file:", "explicit:"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q does not name provider %s", err, name)
+		}
+	}
+	if _, err := (ChainProvider{}).MachineID(smallLayout); err == nil {
+		t.Error("an empty chain returned a machine ID")
+	}
+}
+
+func TestParseProviders(t *testing.T) {
+	chain, err := ParseProviders("explicit, env,ordinal,hash,ip,file,mac", ExplicitProvider{ID: 3}, EnvProvider{}, FileProvider{})
+	if err != nil {
+		t.Fatalf("ParseProviders: %v", err)
+	}
+	if got := chain.Name(); got != "explicit,env,ordinal,hash,ip,file,mac" {
+		t.Errorf("Name = %q", got)
+	}
+	if id, err := chain.MachineID(DefaultLayout); err != nil || id != 3 {
+		t.Errorf("MachineID = %d, %v, want the explicit 3", id, err)
+	}
+	if _, err := ParseProviders("env,dns", ExplicitProvider{}, EnvProvider{}, FileProvider{}); err == nil || !strings.Contains(err.Error(), `"dns"`) {
+		t.Errorf("ParseProviders with an unknown name: got %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+// Package registry detects machine ID collisions between generators that
+// share a directory, such as an NFS mount or a host path mounted into every
+// container of a node.
+//
+// Machine IDs derived from MAC addresses, hostnames or IPs are not
+// guaranteed to be unique. As a cheap safeguard, every generator registers
+// the machine ID it resolved in the registry directory at startup: one
+// claim file per machine ID, holding the hostname, PID and expiry of the
+// holder. A claim stays live while its holder renews it on a heartbeat;
+// a claim that has not been renewed within the TTL is stale and can be
+// taken over. A generator whose machine ID is claimed by a live peer refuses
+// to start or, with RegisterAny, picks the lowest free machine ID instead.
+//
+// Claims are created with O_EXCL and replaced with atomic renames, which is
+// enough to catch misconfigured fleets but is not a consensus protocol: a
+// holder that stalls for longer than the TTL and then resumes may briefly
+// overlap with the peer that took over its claim. Expiry times are compared
+// with the local wall clock, so the hosts' clocks must roughly agree; use a
+// TTL well above the expected clock skew. For strict exclusivity use the
+// MySQL leases of package lease.
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/lease"
+)
+
+// Defaults used when a Config field is left empty.
+const DefaultTTL = 30 * time.Second
+
+// Errors reported by the registry.
+var (
+	// ErrMachineIDClaimed is returned when a live peer holds the machine ID.
+	ErrMachineIDClaimed = errors.New("machine ID claimed by a live peer")
+	// ErrNoFreeMachineID is returned by RegisterAny when every machine ID is claimed.
+	ErrNoFreeMachineID = errors.New("no free machine ID")
+)
+
+// claimPrefix and claimSuffix frame the machine ID in claim file names.
+const (
+	claimPrefix = "machine-"
+	claimSuffix = ".json"
+)
+
+// Worker is the content of a claim file.
+type Worker struct {
+	// The claimed machine ID.
+	MachineID int64 `json:"machine_id"`
+	// The host and process of the holder.
+	Hostname string `json:"hostname"`
+	PID      int    `json:"pid"`
+	// Identifies the holder; unique per registration.
+	Owner string `json:"owner"`
+	// When the claim was first written.
+	RegisteredAt time.Time `json:"registered_at"`
+	// When the claim expires unless renewed.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Live reports whether the claim has not expired at now.
+func (w Worker) Live(now time.Time) bool {
+	return now.Before(w.ExpiresAt)
+}
+
+// String describes the holder of the claim.
+func (w Worker) String() string {
+	return fmt.Sprintf("machine ID %d held by %s (pid %d) until %s", w.MachineID, w.Hostname, w.PID, w.ExpiresAt.Format(time.RFC3339))
+}
+
+// Config configures a Dir registry.
+type Config struct {
+	// The shared registry directory; created if missing.
+	Dir string
+	// How long a claim stays live without renewal (DefaultTTL if zero).
+	TTL time.Duration
+	// How often claims are renewed (a third of TTL if zero).
+	HeartbeatInterval time.Duration
+	// The hostname recorded in claims (os.Hostname if empty).
+	Hostname string
+	// The layout whose machine IDs RegisterAny picks from
+	// (snowflake.DefaultLayout if zero).
+	Layout snowflake.Layout
+}
+
+// Dir is a registry kept in a directory of claim files.
+type Dir struct {
+	// The configuration, with defaults filled in.
+	cfg Config
+	// The owner token written into claims, unique to this Dir.
+	owner string
+}
+
+// NewDir returns a registry in cfg.Dir, creating the directory if needed.
+func NewDir(cfg Config) (*Dir, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("no registry directory")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = cfg.TTL / 3
+	}
+	if cfg.HeartbeatInterval >= cfg.TTL {
+		return nil, fmt.Errorf("heartbeat interval %v must be shorter than the TTL %v", cfg.HeartbeatInterval, cfg.TTL)
+	}
+	if cfg.Hostname == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname for registry: %w", err)
+		}
+		cfg.Hostname = hostname
+	}
+	if cfg.Layout == (snowflake.Layout{}) {
+		cfg.Layout = snowflake.DefaultLayout
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create registry directory: %w", err)
+	}
+
+	// The random part tells apart registrations of a restarted process
+	// that got the same PID.
+	var nonce [4]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	owner := cfg.Hostname + ":" + strconv.Itoa(os.Getpid()) + ":" + hex.EncodeToString(nonce[:])
+	return &Dir{cfg: cfg, owner: owner}, nil
+}
+
+// Register claims machineID. It fails with ErrMachineIDClaimed, naming the
+// holder, if a live peer already claims it. Stale claims are taken over.
+func (d *Dir) Register(machineID int64) (*Registration, error) {
+	if machineID < 0 || machineID > d.cfg.Layout.MaxMachineID() {
+		return nil, fmt.Errorf("%w: %d is not between 0 and %d", snowflake.ErrInvalidMachineID, machineID, d.cfg.Layout.MaxMachineID())
+	}
+	w, err := d.claim(machineID)
+	if err != nil {
+		return nil, err
+	}
+	return d.start(w), nil
+}
+
+// RegisterAny claims preferred if no live peer holds it, and otherwise the
+// lowest machine ID of the layout without a live claim.
+func (d *Dir) RegisterAny(preferred int64) (*Registration, error) {
+	r, err := d.Register(preferred)
+	if !errors.Is(err, ErrMachineIDClaimed) {
+		return r, err
+	}
+	log.Printf("Machine ID %d is taken (%v), picking another", preferred, err)
+	for id := int64(0); id <= d.cfg.Layout.MaxMachineID(); id++ {
+		if id == preferred {
+			continue
+		}
+		w, err := d.claim(id)
+		if errors.Is(err, ErrMachineIDClaimed) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return d.start(w), nil
+	}
+	return nil, fmt.Errorf("%w: all %d machine IDs are claimed", ErrNoFreeMachineID, d.cfg.Layout.MaxMachineID()+1)
+}
+
+// List returns every claim in the registry, live or stale, ordered by
+// machine ID. Unreadable claim files are logged and skipped.
+func (d *Dir) List() ([]Worker, error) {
+	return List(d.cfg.Dir)
+}
+
+// List returns every claim in the registry directory dir, like Dir.List,
+// without registering anything.
+func List(dir string) ([]Worker, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry directory: %w", err)
+	}
+	var workers []Worker
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, claimPrefix) || !strings.HasSuffix(name, claimSuffix) {
+			continue
+		}
+		w, err := readClaim(filepath.Join(dir, name))
+		if err != nil {
+			// The claim may have been released since the directory was read.
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Skipping claim file %s: %v", name, err)
+			}
+			continue
+		}
+		workers = append(workers, w)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].MachineID < workers[j].MachineID })
+	return workers, nil
+}
+
+// claim writes a claim on machineID, taking over a stale claim.
+func (d *Dir) claim(machineID int64) (Worker, error) {
+	path := d.path(machineID)
+	// A few attempts cover claims released or taken over concurrently.
+	for attempt := 0; attempt < 3; attempt++ {
+		now := time.Now()
+		w := Worker{
+			MachineID:    machineID,
+			Hostname:     d.cfg.Hostname,
+			PID:          os.Getpid(),
+			Owner:        d.owner,
+			RegisteredAt: now,
+			ExpiresAt:    now.Add(d.cfg.TTL),
+		}
+		err := createClaim(path, w)
+		if err == nil {
+			return w, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return Worker{}, fmt.Errorf("failed to claim machine ID %d: %w", machineID, err)
+		}
+
+		holder, err := readClaim(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Worker{}, fmt.Errorf("failed to read claim on machine ID %d: %w", machineID, err)
+		}
+		if holder.Live(now) {
+			return Worker{}, fmt.Errorf("%w: %v", ErrMachineIDClaimed, holder)
+		}
+		log.Printf("Taking over stale claim: %v", holder)
+		if err := d.removeStale(path, holder); err != nil {
+			return Worker{}, fmt.Errorf("failed to remove stale claim on machine ID %d: %w", machineID, err)
+		}
+	}
+	return Worker{}, fmt.Errorf("failed to claim machine ID %d: the claim kept changing", machineID)
+}
+
+// removeStale removes the claim file at path if it still holds the stale
+// claim holder. The file is first renamed to a name of our own, so that of
+// two registries taking over the same claim only one removes it; a claim
+// that was renewed or replaced in the meantime is put back.
+func (d *Dir) removeStale(path string, holder Worker) error {
+	aside := path + ".stale-" + d.owner
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	moved, err := readClaim(aside)
+	if err == nil && (moved.Owner != holder.Owner || !moved.ExpiresAt.Equal(holder.ExpiresAt)) {
+		// Not the claim we judged stale: restore it unless a new claim
+		// has appeared, in which case the next attempt sees that one.
+		if err := os.Link(aside, path); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return os.Remove(aside)
+}
+
+// path returns the claim file of a machine ID.
+func (d *Dir) path(machineID int64) string {
+	return filepath.Join(d.cfg.Dir, claimPrefix+strconv.FormatInt(machineID, 10)+claimSuffix)
+}
+
+// Renew implements lease.Store. It extends our claim on machineID in a
+// temporary file renamed over the claim file.
+func (d *Dir) Renew(ctx context.Context, machineID int64) (bool, error) {
+	path := d.path(machineID)
+	current, err := readClaim(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if current.Owner != d.owner {
+		return false, nil
+	}
+
+	current.ExpiresAt = time.Now().Add(d.cfg.TTL)
+	tmp := path + ".tmp-" + d.owner
+	if err := writeClaim(tmp, current, os.O_WRONLY|os.O_CREATE|os.O_TRUNC); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// Release implements lease.Store. It removes our claim on machineID, if it
+// is still ours.
+func (d *Dir) Release(ctx context.Context, machineID int64) error {
+	path := d.path(machineID)
+	current, err := readClaim(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.Owner != d.owner {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// createClaim writes a new claim file, failing with fs.ErrExist if one exists.
+func createClaim(path string, w Worker) error {
+	return writeClaim(path, w, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+}
+
+// writeClaim writes w as JSON to path, opened with flag.
+func writeClaim(path string, w Worker, flag int) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readClaim reads the claim file at path.
+func readClaim(path string) (Worker, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Worker{}, err
+	}
+	var w Worker
+	if err := json.Unmarshal(data, &w); err != nil {
+		return Worker{}, fmt.Errorf("invalid claim file: %w", err)
+	}
+	return w, nil
+}
+
+// start returns a registration for a freshly written claim and starts
+// renewing it.
+func (d *Dir) start(w Worker) *Registration {
+	r := &Registration{
+		Lease:  lease.New(d, w.MachineID, w.ExpiresAt, d.cfg.TTL, d.cfg.HeartbeatInterval),
+		worker: w,
+	}
+	log.Printf("Registered machine ID %d in %s as %s (ttl %v)", w.MachineID, d.cfg.Dir, d.owner, d.cfg.TTL)
+	return r
+}
+
+// Registration is a held claim on a machine ID: a lease.Lease renewed in
+// the registry directory. It implements snowflake.MachineIDLease, so a
+// generator stops issuing IDs once the claim is lost; Err then reports
+// lease.ErrLeaseLost, and lease.ErrLeaseReleased after Release.
+type Registration struct {
+	*lease.Lease
+	// The claim as written.
+	worker Worker
+}
+
+// Worker returns the claim as it was first written.
+func (r *Registration) Worker() Worker {
+	return r.worker
+}
@@ -0,0 +1,233 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/lease"
+)
+
+// testLayout has four machine IDs, so RegisterAny runs out quickly.
+var testLayout = snowflake.Layout{TimestampBits: 49, WorkerBits: 2, SequenceBits: 12, Epoch: snowflake.DefaultEpoch}
+
+// newDir returns a registry in dir for the given host.
+func newDir(t *testing.T, dir, hostname string, ttl, heartbeat time.Duration) *Dir {
+	t.Helper()
+	d, err := NewDir(Config{Dir: dir, TTL: ttl, HeartbeatInterval: heartbeat, Hostname: hostname, Layout: testLayout})
+	if err != nil {
+		t.Fatalf("NewDir: %v", err)
+	}
+	return d
+}
+
+// register claims machineID and releases it when the test ends.
+func register(t *testing.T, d *Dir, machineID int64) *Registration {
+	t.Helper()
+	r, err := d.Register(machineID)
+	if err != nil {
+		t.Fatalf("Register(%d): %v", machineID, err)
+	}
+	t.Cleanup(func() { r.Release(context.Background()) })
+	return r
+}
+
+// claimOf returns the claim on machineID listed in d.
+func claimOf(t *testing.T, d *Dir, machineID int64) Worker {
+	t.Helper()
+	workers, err := d.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for _, w := range workers {
+		if w.MachineID == machineID {
+			return w
+		}
+	}
+	t.Fatalf("no claim on machine ID %d", machineID)
+	return Worker{}
+}
+
+func TestRegisterRefusesLivePeer(t *testing.T) {
+	dir := t.TempDir()
+	a := newDir(t, dir, "host-a", time.Minute, time.Second)
+	b := newDir(t, dir, "host-b", time.Minute, time.Second)
+
+	r := register(t, a, 2)
+	if r.MachineID() != 2 || r.Worker().Hostname != "host-a" {
+		t.Errorf("registration = %d by %s, want 2 by host-a", r.MachineID(), r.Worker().Hostname)
+	}
+	_, err := b.Register(2)
+	if !errors.Is(err, ErrMachineIDClaimed) || !strings.Contains(err.Error(), "host-a") {
+		t.Fatalf("Register of a claimed machine ID: got %v, want ErrMachineIDClaimed naming host-a", err)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("the holder's Err after a refused peer = %v", err)
+	}
+	if _, err := a.Register(4); !errors.Is(err, snowflake.ErrInvalidMachineID) {
+		t.Errorf("Register outside the layout: got %v, want ErrInvalidMachineID", err)
+	}
+}
+
+func TestRegisterAnyPicksLowestFree(t *testing.T) {
+	dir := t.TempDir()
+	a := newDir(t, dir, "host-a", time.Minute, time.Second)
+	b := newDir(t, dir, "host-b", time.Minute, time.Second)
+	register(t, a, 0)
+	register(t, a, 2)
+
+	// A free preferred machine ID is taken as is.
+	r, err := b.RegisterAny(3)
+	if err != nil {
+		t.Fatalf("RegisterAny(3): %v", err)
+	}
+	defer r.Release(context.Background())
+	if r.MachineID() != 3 {
+		t.Errorf("RegisterAny(3) registered %d", r.MachineID())
+	}
+
+	// A claimed one is replaced by the lowest free machine ID.
+	r, err = b.RegisterAny(2)
+	if err != nil {
+		t.Fatalf("RegisterAny(2): %v", err)
+	}
+	defer r.Release(context.Background())
+	if r.MachineID() != 1 {
+		t.Errorf("RegisterAny(2) registered %d, want 1", r.MachineID())
+	}
+
+	if _, err := b.RegisterAny(0); !errors.Is(err, ErrNoFreeMachineID) {
+		t.Errorf("RegisterAny with every machine ID claimed: got %v, want ErrNoFreeMachineID", err)
+	}
+}
+
+func TestRegisterTakesOverStaleClaim(t *testing.T) {
+	dir := t.TempDir()
+	// A holder that died without releasing its claim.
+	stale := Worker{MachineID: 1, Hostname: "host-dead", PID: 1, Owner: "host-dead:1:00000000",
+		RegisteredAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(-time.Minute)}
+	a := newDir(t, dir, "host-a", time.Minute, time.Second)
+	if err := createClaim(a.path(1), stale); err != nil {
+		t.Fatalf("createClaim: %v", err)
+	}
+
+	r := register(t, a, 1)
+	if w := claimOf(t, a, 1); w.Owner != r.Worker().Owner || !w.Live(time.Now()) {
+		t.Errorf("claim after the takeover = %+v, want a live claim by host-a", w)
+	}
+	// No renamed-aside files are left behind.
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("registry directory holds %d files, want 1", len(entries))
+	}
+}
+
+func TestRegistrationLostOnTakeover(t *testing.T) {
+	dir := t.TempDir()
+	a := newDir(t, dir, "host-a", time.Minute, 10*time.Millisecond)
+	r := register(t, a, 1)
+
+	// Another holder replaces the claim, as after a stall beyond the TTL.
+	w := r.Worker()
+	w.Owner, w.Hostname = "host-b:2:00000000", "host-b"
+	if err := writeClaim(a.path(1), w, os.O_WRONLY|os.O_TRUNC); err != nil {
+		t.Fatalf("writeClaim: %v", err)
+	}
+	select {
+	case <-r.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("registration not lost after its claim was replaced")
+	}
+	if err := r.Err(); !errors.Is(err, lease.ErrLeaseLost) {
+		t.Errorf("Err = %v, want lease.ErrLeaseLost", err)
+	}
+	// Releasing a lost registration leaves the new holder's claim alone.
+	if err := r.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if got := claimOf(t, a, 1); got.Hostname != "host-b" {
+		t.Errorf("claim after releasing the lost registration is held by %s, want host-b", got.Hostname)
+	}
+}
+
+func TestRegistrationHeartbeat(t *testing.T) {
+	dir := t.TempDir()
+	a := newDir(t, dir, "host-a", 100*time.Millisecond, 20*time.Millisecond)
+	r := register(t, a, 1)
+	first := claimOf(t, a, 1).ExpiresAt
+
+	// Outlive the TTL several times; only the heartbeat keeps the claim.
+	time.Sleep(300 * time.Millisecond)
+	if err := r.Err(); err != nil {
+		t.Fatalf("Err after heartbeats: %v", err)
+	}
+	w := claimOf(t, a, 1)
+	if !w.ExpiresAt.After(first.Add(150*time.Millisecond)) || !w.Live(time.Now()) {
+		t.Errorf("claim expires at %v, want renewed well past %v", w.ExpiresAt, first)
+	}
+	if !w.RegisteredAt.Equal(r.Worker().RegisteredAt) {
+		t.Errorf("RegisteredAt changed from %v to %v", r.Worker().RegisteredAt, w.RegisteredAt)
+	}
+
+	// Release removes the claim and frees the machine ID.
+	if err := r.Release(context.Background()); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := r.Err(); !errors.Is(err, lease.ErrLeaseReleased) {
+		t.Errorf("Err after Release = %v, want lease.ErrLeaseReleased", err)
+	}
+	b := newDir(t, dir, "host-b", time.Minute, time.Second)
+	register(t, b, 1)
+}
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	a := newDir(t, dir, "host-a", time.Minute, time.Second)
+	register(t, a, 3)
+	register(t, a, 0)
+	stale := Worker{MachineID: 2, Hostname: "host-dead", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := createClaim(a.path(2), stale); err != nil {
+		t.Fatalf("createClaim: %v", err)
+	}
+	// Unrelated and corrupt files are skipped.
+	os.WriteFile(filepath.Join(dir, "README"), []byte("claims\n"), 0o644)
+	os.WriteFile(a.path(1), []byte("{"), 0o644)
+
+	workers, err := List(dir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(workers) != 3 {
+		t.Fatalf("List returned %d claims, want 3: %+v", len(workers), workers)
+	}
+	now := time.Now()
+	for i, want := range []struct {
+		machineID int64
+		hostname  string
+		live      bool
+	}{{0, "host-a", true}, {2, "host-dead", false}, {3, "host-a", true}} {
+		w := workers[i]
+		if w.MachineID != want.machineID || w.Hostname != want.hostname || w.Live(now) != want.live {
+			t.Errorf("claim %d = %v (live %v), want machine ID %d by %s (live %v)",
+				i, w, w.Live(now), want.machineID, want.hostname, want.live)
+		}
+	}
+
+	if _, err := List(filepath.Join(dir, "missing")); err == nil {
+		t.Error("List of a missing directory succeeded")
+	}
+}
+
+func TestNewDirInvalid(t *testing.T) {
+	if _, err := NewDir(Config{}); err == nil {
+		t.Error("NewDir without a directory succeeded")
+	}
+	if _, err := NewDir(Config{Dir: t.TempDir(), TTL: time.Second, HeartbeatInterval: time.Second}); err == nil {
+		t.Error("NewDir with a heartbeat as long as the TTL succeeded")
+	}
+}
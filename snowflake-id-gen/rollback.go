@@ -0,0 +1,223 @@
+package snowflake
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DefaultRollbackTolerance is the largest clock regression the RollbackWait
+// and RollbackBorrow strategies absorb unless configured otherwise.
+const DefaultRollbackTolerance = 10 * time.Millisecond
+
+// RollbackStrategy selects how a generator reacts when the clock moves backwards.
+type RollbackStrategy int
+
+const (
+	// RollbackFail returns ErrClockMovedBackwards immediately. This is the default.
+	RollbackFail RollbackStrategy = iota
+	// RollbackWait blocks until the clock catches up with the last issued
+	// timestamp, provided the regression is within the tolerance.
+	RollbackWait
+	// RollbackBorrow keeps issuing IDs from the last issued timestamp,
+	// borrowing sequence space from future ticks, provided the
+	// generator does not get further ahead of the clock than the tolerance.
+	RollbackBorrow
+	// RollbackSwitchMachineID switches to a spare machine ID that has not
+	// issued IDs at or after the current timestamp. Spare IDs must be
+	// reserved exclusively for this generator. IDs stay unique, but IDs
+	// issued after the switch sort before those issued just before it.
+	RollbackSwitchMachineID
+)
+
+// rollbackStrategyNames maps strategies to the names used by String and
+// ParseRollbackStrategy.
+var rollbackStrategyNames = map[RollbackStrategy]string{
+	RollbackFail:            "fail",
+	RollbackWait:            "wait",
+	RollbackBorrow:          "borrow",
+	RollbackSwitchMachineID: "switch",
+}
+
+// String returns the name of the strategy.
+func (r RollbackStrategy) String() string {
+	if name, ok := rollbackStrategyNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("RollbackStrategy(%d)", int(r))
+}
+
+// ParseRollbackStrategy parses one of "fail", "wait", "borrow" or "switch".
+func ParseRollbackStrategy(s string) (RollbackStrategy, error) {
+	for strategy, name := range rollbackStrategyNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return strategy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rollback strategy %q (want fail, wait, borrow or switch)", s)
+}
+
+// RollbackEvent describes one detected clock regression and how it was handled.
+type RollbackEvent struct {
+	// The strategy that handled the regression.
+	Strategy RollbackStrategy
+	// The last issued timestamp (ticks since the epoch).
+	LastTimestamp int64
+	// The timestamp read from the clock (ticks since the epoch).
+	CurrentTimestamp int64
+	// How far the clock moved backwards.
+	Backwards time.Duration
+	// The machine ID in use after handling the regression.
+	MachineID int64
+	// Non-nil if the regression could not be absorbed and ID generation failed.
+	Err error
+}
+
+// RollbackStats counts clock regressions seen by a generator.
+type RollbackStats struct {
+	// Regressions detected.
+	Events uint64
+	// Regressions absorbed by waiting for the clock.
+	Waits uint64
+	// Regressions absorbed by borrowing future sequence space.
+	Borrows uint64
+	// Regressions absorbed by switching to a spare machine ID.
+	Switches uint64
+	// Regressions that made ID generation fail.
+	Failures uint64
+}
+
+// WithRollbackStrategy selects how the generator reacts when the clock moves
+// backwards. The default is RollbackFail.
+func WithRollbackStrategy(strategy RollbackStrategy) Option {
+	return func(s *Snowflake) {
+		s.rollbackStrategy = strategy
+	}
+}
+
+// WithRollbackTolerance sets the largest regression RollbackWait waits out
+// and the furthest RollbackBorrow runs ahead of the clock. The default is
+// DefaultRollbackTolerance.
+func WithRollbackTolerance(d time.Duration) Option {
+	return func(s *Snowflake) {
+		s.rollbackTolerance = d
+	}
+}
+
+// WithSpareMachineIDs sets the machine IDs RollbackSwitchMachineID may switch to.
+func WithSpareMachineIDs(ids ...int64) Option {
+	return func(s *Snowflake) {
+		s.spareMachineIDs = append(s.spareMachineIDs, ids...)
+	}
+}
+
+// WithRollbackHook registers a function called for every detected clock
+// regression. It runs after the generator's lock is released, on the
+// goroutine that observed the regression.
+func WithRollbackHook(hook func(RollbackEvent)) Option {
+	return func(s *Snowflake) {
+		s.onRollback = hook
+	}
+}
+
+// RollbackStats returns the clock regression counters of the generator.
+func (s *Snowflake) RollbackStats() RollbackStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rollbackStats
+}
+
+// handleRollback is called with the lock held when the clock reads a
+// timestamp before lastTimestamp. It applies the configured strategy and
+// returns the timestamp to continue with, which is never before
+// lastTimestamp. The returned event is nil when the regression was already
+// reported (RollbackBorrow keeps borrowing until the clock catches up).
+// RollbackWait only waits if wait is set, and gives up once ctx is done.
+func (s *Snowflake) handleRollback(ctx context.Context, wait bool, current int64) (int64, *RollbackEvent, error) {
+	// A borrowing generator keeps seeing the same regression until the clock
+	// catches up; only the first observation is reported.
+	if s.rollbackStrategy == RollbackBorrow && s.borrowing {
+		if s.withinTolerance(s.lastTimestamp - current) {
+			return s.lastTimestamp, nil, nil
+		}
+	}
+
+	// Log the event for monitoring purposes.
+	log.Printf("Clock moved backwards detected. Current: %d, Last: %d", current, s.lastTimestamp)
+	s.rollbackStats.Events++
+	event := &RollbackEvent{
+		Strategy:         s.rollbackStrategy,
+		LastTimestamp:    s.lastTimestamp,
+		CurrentTimestamp: current,
+		Backwards:        s.base.duration(s.lastTimestamp - current),
+	}
+
+	switch s.rollbackStrategy {
+	case RollbackWait:
+		if wait && s.withinTolerance(s.lastTimestamp-current) {
+			// Sleep until the clock is back at the last issued timestamp.
+			for current < s.lastTimestamp {
+				if err := s.sleep(ctx, s.base.duration(s.lastTimestamp-current)); err != nil {
+					s.rollbackStats.Failures++
+					event.MachineID = s.machineID
+					event.Err = fmt.Errorf("waiting for the clock to catch up: %w", err)
+					return 0, event, event.Err
+				}
+				current = s.currentTimestamp()
+			}
+			s.rollbackStats.Waits++
+			event.MachineID = s.machineID
+			return current, event, nil
+		}
+	case RollbackBorrow:
+		if s.withinTolerance(s.lastTimestamp - current) {
+			// Continue from the last timestamp; GenerateID advances it past
+			// the clock when the sequence overflows.
+			s.borrowing = true
+			s.rollbackStats.Borrows++
+			event.MachineID = s.machineID
+			return s.lastTimestamp, event, nil
+		}
+	case RollbackSwitchMachineID:
+		if spare, ok := s.switchMachineID(current); ok {
+			s.rollbackStats.Switches++
+			event.MachineID = spare
+			return current, event, nil
+		}
+	}
+
+	// The regression cannot be absorbed: generating an ID could break monotonicity.
+	s.rollbackStats.Failures++
+	event.MachineID = s.machineID
+	event.Err = fmt.Errorf("%w: clock is %v behind the last issued timestamp (strategy %s)", ErrClockMovedBackwards, event.Backwards, s.rollbackStrategy)
+	return 0, event, event.Err
+}
+
+// withinTolerance reports whether a gap in ticks is within the configured tolerance.
+func (s *Snowflake) withinTolerance(gap int64) bool {
+	return s.base.duration(gap) <= s.rollbackTolerance
+}
+
+// switchMachineID swaps the active machine ID for a spare one that has only
+// issued IDs before current, and makes that spare's history the generator's
+// state. It reports false if no spare is usable.
+func (s *Snowflake) switchMachineID(current int64) (int64, bool) {
+	for _, spare := range s.spareMachineIDs {
+		// Spares that never issued an ID have a last timestamp of -1.
+		last, used := s.machineIDLast[spare]
+		if !used {
+			last = -1
+		}
+		if spare == s.machineID || last >= current {
+			continue
+		}
+		// Remember where the outgoing machine ID stopped.
+		s.machineIDLast[s.machineID] = s.lastTimestamp
+		s.machineID = spare
+		s.lastTimestamp = last
+		return spare, true
+	}
+	return 0, false
+}
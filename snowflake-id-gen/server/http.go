@@ -0,0 +1,168 @@
+// Package server exposes a Snowflake generator over the network so that
+// services written in other languages can fetch IDs without embedding Go:
+// over HTTP with HTTPHandler, and over the Redis protocol with RESPServer.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// DefaultMaxCount is the largest number of IDs a single GET /ids request may
+// ask for unless configured otherwise.
+const DefaultMaxCount = 10000
+
+// HTTPHandler serves IDs from a Snowflake generator:
+//
+//	GET /id                one new ID
+//	GET /ids?count=N       N new IDs, reserved as one block
+//	GET /decode/{id}       the components of an ID
+//	GET /healthz           liveness and generator configuration
+//
+// IDs are encoded as JSON strings (see snowflake.ID) because JavaScript
+// numbers cannot hold 64-bit integers without losing precision.
+type HTTPHandler struct {
+	// The generator IDs are taken from.
+	gen *snowflake.Snowflake
+	// The largest count accepted by GET /ids.
+	maxCount int
+	// The request router.
+	mux *http.ServeMux
+}
+
+// idResponse is the body of GET /id.
+type idResponse struct {
+	ID snowflake.ID `json:"id"`
+}
+
+// idsResponse is the body of GET /ids.
+type idsResponse struct {
+	IDs []snowflake.ID `json:"ids"`
+}
+
+// decodeResponse is the body of GET /decode/{id}.
+type decodeResponse struct {
+	ID           int64     `json:"id,string"`
+	Time         time.Time `json:"time"`
+	Timestamp    int64     `json:"timestamp"`
+	MachineID    int64     `json:"machine_id"`
+	DatacenterID int64     `json:"datacenter_id"`
+	WorkerID     int64     `json:"worker_id"`
+	Sequence     int64     `json:"sequence"`
+}
+
+// healthResponse is the body of GET /healthz.
+type healthResponse struct {
+	Status    string `json:"status"`
+	MachineID int64  `json:"machine_id"`
+	Layout    string `json:"layout"`
+}
+
+// errorResponse is the body of every failed request.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHTTPHandler returns a handler serving IDs from gen. maxCount bounds
+// GET /ids; a value <= 0 selects DefaultMaxCount.
+func NewHTTPHandler(gen *snowflake.Snowflake, maxCount int) *HTTPHandler {
+	if maxCount <= 0 {
+		maxCount = DefaultMaxCount
+	}
+	h := &HTTPHandler{gen: gen, maxCount: maxCount, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /id", h.handleID)
+	h.mux.HandleFunc("GET /ids", h.handleIDs)
+	h.mux.HandleFunc("GET /decode/{id}", h.handleDecode)
+	h.mux.HandleFunc("GET /healthz", h.handleHealth)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// handleID serves GET /id.
+func (h *HTTPHandler) handleID(w http.ResponseWriter, r *http.Request) {
+	id, err := h.gen.GenerateIDContext(r.Context())
+	if err != nil {
+		h.writeGenerateError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, idResponse{ID: snowflake.ID(id)})
+}
+
+// handleIDs serves GET /ids?count=N.
+func (h *HTTPHandler) handleIDs(w http.ResponseWriter, r *http.Request) {
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count <= 0 || count > h.maxCount {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("count must be an integer between 1 and %d", h.maxCount)})
+		return
+	}
+	ids, err := h.gen.GenerateN(count)
+	if err != nil {
+		h.writeGenerateError(w, err)
+		return
+	}
+	resp := idsResponse{IDs: make([]snowflake.ID, len(ids))}
+	for i, id := range ids {
+		resp.IDs[i] = snowflake.ID(id)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleDecode serves GET /decode/{id}.
+func (h *HTTPHandler) handleDecode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid ID %q", r.PathValue("id"))})
+		return
+	}
+	parts, err := h.gen.Decode(id)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, decodeResponse(parts))
+}
+
+// handleHealth serves GET /healthz. It reports 503 once the generator's
+// machine ID lease is lost, since no more IDs can be issued.
+func (h *HTTPHandler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if err := h.gen.LeaseErr(); err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthResponse{
+		Status:    "ok",
+		MachineID: h.gen.MachineID(),
+		Layout:    h.gen.Layout().String(),
+	})
+}
+
+// writeGenerateError maps a generation error to a response. Clock
+// regressions and lost machine ID leases are reported as 503.
+func (h *HTTPHandler) writeGenerateError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, snowflake.ErrClockMovedBackwards) || h.gen.LeaseErr() != nil {
+		status = http.StatusServiceUnavailable
+	}
+	log.Printf("Error generating ID: %v", err)
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
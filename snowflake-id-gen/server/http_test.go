@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// testLease is a machine ID lease whose error is set by the test.
+type testLease struct {
+	machineID int64
+	err       error
+}
+
+func (l *testLease) MachineID() int64 { return l.machineID }
+func (l *testLease) Err() error       { return l.err }
+
+// serveHTTP sends a request to h and returns the recorded response.
+func serveHTTP(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+// decodeBody decodes the JSON body of rec into v.
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+}
+
+// newTestHandler returns a handler serving a generator with machine ID 7.
+func newTestHandler(t *testing.T, opts ...snowflake.Option) (*HTTPHandler, *snowflake.Snowflake) {
+	t.Helper()
+	gen, err := snowflake.NewSnowflake(7, opts...)
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	return NewHTTPHandler(gen, 100), gen
+}
+
+func TestHTTPID(t *testing.T) {
+	h, gen := newTestHandler(t)
+	rec := serveHTTP(h, "GET", "/id")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /id: status %d, body %s", rec.Code, rec.Body)
+	}
+	// IDs are sent as strings so JavaScript clients do not lose precision.
+	var raw map[string]any
+	decodeBody(t, rec, &raw)
+	s, ok := raw["id"].(string)
+	if !ok {
+		t.Fatalf("GET /id: id is %T, want a string", raw["id"])
+	}
+	id, err := snowflake.ParseID(s)
+	if err != nil {
+		t.Fatalf("ParseID(%q): %v", s, err)
+	}
+	parts, err := gen.Decode(int64(id))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if parts.MachineID != 7 {
+		t.Errorf("machine ID = %d, want 7", parts.MachineID)
+	}
+}
+
+func TestHTTPIDs(t *testing.T) {
+	h, _ := newTestHandler(t)
+	rec := serveHTTP(h, "GET", "/ids?count=5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /ids: status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp idsResponse
+	decodeBody(t, rec, &resp)
+	if len(resp.IDs) != 5 {
+		t.Fatalf("got %d IDs, want 5", len(resp.IDs))
+	}
+	for i := 1; i < len(resp.IDs); i++ {
+		if resp.IDs[i] <= resp.IDs[i-1] {
+			t.Errorf("IDs not increasing: %d after %d", resp.IDs[i], resp.IDs[i-1])
+		}
+	}
+
+	for _, query := range []string{"", "?count=", "?count=abc", "?count=0", "?count=-1", "?count=101"} {
+		rec := serveHTTP(h, "GET", "/ids"+query)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /ids%s: status %d, want 400", query, rec.Code)
+			continue
+		}
+		var resp errorResponse
+		decodeBody(t, rec, &resp)
+		if !strings.Contains(resp.Error, "between 1 and 100") {
+			t.Errorf("GET /ids%s: error %q does not name the bounds", query, resp.Error)
+		}
+	}
+}
+
+func TestHTTPDecode(t *testing.T) {
+	h, gen := newTestHandler(t)
+	id, err := gen.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID: %v", err)
+	}
+	want, _ := gen.Decode(id)
+
+	rec := serveHTTP(h, "GET", "/decode/"+snowflake.ID(id).String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /decode: status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp decodeResponse
+	decodeBody(t, rec, &resp)
+	if resp.ID != id || !resp.Time.Equal(want.Time) || resp.MachineID != 7 || resp.Sequence != want.Sequence {
+		t.Errorf("GET /decode = %+v, want the parts %+v", resp, want)
+	}
+
+	for _, path := range []string{"/decode/abc", "/decode/1.5", "/decode/-1", "/decode/99999999999999999999"} {
+		rec := serveHTTP(h, "GET", path)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: status %d, want 400", path, rec.Code)
+		}
+	}
+}
+
+func TestHTTPRoutes(t *testing.T) {
+	h, _ := newTestHandler(t)
+	tests := []struct {
+		method, target string
+		want           int
+	}{
+		{"POST", "/id", http.StatusMethodNotAllowed},
+		{"DELETE", "/ids?count=1", http.StatusMethodNotAllowed},
+		{"PUT", "/healthz", http.StatusMethodNotAllowed},
+		{"GET", "/decode/", http.StatusNotFound},
+		{"GET", "/unknown", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := serveHTTP(h, tt.method, tt.target); rec.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHTTPHealth(t *testing.T) {
+	lease := &testLease{machineID: 7}
+	h, _ := newTestHandler(t, snowflake.WithLease(lease))
+	rec := serveHTTP(h, "GET", "/healthz")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /healthz: status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp healthResponse
+	decodeBody(t, rec, &resp)
+	if resp.Status != "ok" || resp.MachineID != 7 || resp.Layout != snowflake.DefaultLayout.String() {
+		t.Errorf("GET /healthz = %+v", resp)
+	}
+}
+
+func TestHTTPLeaseLost(t *testing.T) {
+	lease := &testLease{machineID: 7}
+	h, _ := newTestHandler(t, snowflake.WithLease(lease))
+	lease.err = errors.New("lease lost")
+
+	for _, target := range []string{"/id", "/ids?count=2", "/healthz"} {
+		rec := serveHTTP(h, "GET", target)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s: status %d, want 503", target, rec.Code)
+			continue
+		}
+		var resp errorResponse
+		decodeBody(t, rec, &resp)
+		if !strings.Contains(resp.Error, "lease lost") {
+			t.Errorf("GET %s: error %q does not mention the lease", target, resp.Error)
+		}
+	}
+}
+
+func TestHTTPClockMovedBackwards(t *testing.T) {
+	clock := snowflake.NewManualClock(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	h, _ := newTestHandler(t, snowflake.WithClock(clock))
+	if rec := serveHTTP(h, "GET", "/id"); rec.Code != http.StatusOK {
+		t.Fatalf("GET /id: status %d, body %s", rec.Code, rec.Body)
+	}
+	clock.Advance(-time.Second)
+	if rec := serveHTTP(h, "GET", "/id"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /id after a backwards jump: status %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,428 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// Limits on requests read by RESPServer, to bound the memory a client can
+// make the server allocate.
+const (
+	// The most arguments in one command.
+	maxRESPArgs = 64
+	// The longest argument or inline command line, in bytes.
+	maxRESPLine = 64 * 1024
+)
+
+// DefaultRESPIdleTimeout is how long RESPServer waits for the next command
+// of a connection, or for a client to accept a reply, unless configured
+// otherwise.
+const DefaultRESPIdleTimeout = 5 * time.Minute
+
+// ErrRESPServerClosed is returned by RESPServer.Serve after Shutdown.
+var ErrRESPServerClosed = errors.New("RESP server closed")
+
+// errProtocol marks malformed requests; the connection is closed after
+// reporting them, since the rest of the stream cannot be trusted.
+var errProtocol = errors.New("protocol error")
+
+// RESPServer serves IDs from a Snowflake generator over RESP, the Redis
+// protocol, so that existing Redis clients can fetch IDs:
+//
+//	NEXTID          one new ID, as an integer
+//	NEXTIDS n       n new IDs, reserved as one block, as an array of integers
+//	DECODE id       the components of an ID, as field/value pairs like HGETALL
+//	PING [message]  PONG, or the message
+//	ECHO message    the message
+//	QUIT            OK, then the connection is closed
+//
+// Commands are accepted as RESP arrays, as sent by client libraries, and as
+// inline commands, so the server can be driven with nc or telnet. Generation
+// errors are reported as ERR replies, and as UNAVAILABLE replies for clock
+// regressions and lost machine ID leases, mirroring the 503s of HTTPHandler.
+type RESPServer struct {
+	// The generator IDs are taken from.
+	gen *snowflake.Snowflake
+	// The largest count accepted by NEXTIDS.
+	maxCount int
+	// How long a connection may sit idle, or block a reply, before it is closed.
+	idleTimeout time.Duration
+
+	// Mutex to protect the fields below.
+	mu sync.Mutex
+	// The listeners passed to Serve.
+	listeners map[net.Listener]struct{}
+	// The open connections.
+	conns map[net.Conn]struct{}
+	// Set by Shutdown.
+	closed bool
+	// Counts the running connection handlers.
+	wg sync.WaitGroup
+}
+
+// NewRESPServer returns a server handing out IDs from gen. maxCount bounds
+// NEXTIDS; a value <= 0 selects DefaultMaxCount. Connections that send no
+// command, or do not read their replies, for idleTimeout are closed; a value
+// <= 0 selects DefaultRESPIdleTimeout.
+func NewRESPServer(gen *snowflake.Snowflake, maxCount int, idleTimeout time.Duration) *RESPServer {
+	if maxCount <= 0 {
+		maxCount = DefaultMaxCount
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultRESPIdleTimeout
+	}
+	return &RESPServer{
+		gen:         gen,
+		maxCount:    maxCount,
+		idleTimeout: idleTimeout,
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the TCP address addr and calls Serve.
+func (s *RESPServer) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l and serves each in its own goroutine until
+// Shutdown is called or accepting fails. It always closes l and returns a
+// non-nil error, ErrRESPServerClosed after Shutdown.
+func (s *RESPServer) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrRESPServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, l)
+		s.mu.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrRESPServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrRESPServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Shutdown stops accepting connections and closes every connection once
+// the command it is running, if any, has been answered. If ctx is done
+// first, the remaining connections are closed immediately and ctx's error
+// is returned.
+func (s *RESPServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	// Unblock connections waiting for their next command; a command in
+	// progress still gets its reply, then the next read fails.
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// serveConn reads commands from conn and answers them until the client
+// quits, the connection fails or the server shuts down.
+func (s *RESPServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		// Wait for the next command no longer than the idle timeout. The
+		// deadline is set under the lock so that it cannot override the
+		// one set by Shutdown.
+		s.mu.Lock()
+		closed := s.closed
+		if !closed {
+			conn.SetReadDeadline(time.Now().Add(s.idleTimeout))
+		}
+		s.mu.Unlock()
+		if closed {
+			return
+		}
+
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				conn.SetWriteDeadline(time.Now().Add(s.idleTimeout))
+				writeError(w, "ERR", err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			// Blank inline lines are ignored, as by Redis.
+			continue
+		}
+		quit := s.execute(w, args)
+		// Pipelined commands are answered together.
+		if quit || r.Buffered() == 0 {
+			conn.SetWriteDeadline(time.Now().Add(s.idleTimeout))
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute runs one command and writes its reply. It reports whether the
+// connection should be closed.
+func (s *RESPServer) execute(w *bufio.Writer, args []string) (quit bool) {
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+	switch name {
+	case "NEXTID":
+		if len(args) != 0 {
+			writeArityError(w, name)
+			return false
+		}
+		id, err := s.gen.GenerateID()
+		if err != nil {
+			s.writeGenerateError(w, err)
+			return false
+		}
+		writeInteger(w, id)
+	case "NEXTIDS":
+		if len(args) != 1 {
+			writeArityError(w, name)
+			return false
+		}
+		count, err := strconv.Atoi(args[0])
+		if err != nil || count <= 0 || count > s.maxCount {
+			writeError(w, "ERR", fmt.Sprintf("count must be an integer between 1 and %d", s.maxCount))
+			return false
+		}
+		ids, err := s.gen.GenerateN(count)
+		if err != nil {
+			s.writeGenerateError(w, err)
+			return false
+		}
+		fmt.Fprintf(w, "*%d\r\n", len(ids))
+		for _, id := range ids {
+			writeInteger(w, id)
+		}
+	case "DECODE":
+		if len(args) != 1 {
+			writeArityError(w, name)
+			return false
+		}
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			writeError(w, "ERR", fmt.Sprintf("invalid ID %q", truncate(args[0])))
+			return false
+		}
+		parts, err := s.gen.Decode(id)
+		if err != nil {
+			writeError(w, "ERR", err.Error())
+			return false
+		}
+		w.WriteString("*14\r\n")
+		writeBulk(w, "id")
+		writeBulk(w, strconv.FormatInt(parts.ID, 10))
+		writeBulk(w, "time")
+		writeBulk(w, parts.Time.Format(time.RFC3339Nano))
+		writeBulk(w, "timestamp")
+		writeInteger(w, parts.Timestamp)
+		writeBulk(w, "machine_id")
+		writeInteger(w, parts.MachineID)
+		writeBulk(w, "datacenter_id")
+		writeInteger(w, parts.DatacenterID)
+		writeBulk(w, "worker_id")
+		writeInteger(w, parts.WorkerID)
+		writeBulk(w, "sequence")
+		writeInteger(w, parts.Sequence)
+	case "PING":
+		switch len(args) {
+		case 0:
+			w.WriteString("+PONG\r\n")
+		case 1:
+			writeBulk(w, args[0])
+		default:
+			writeArityError(w, name)
+		}
+	case "ECHO":
+		if len(args) != 1 {
+			writeArityError(w, name)
+			return false
+		}
+		writeBulk(w, args[0])
+	case "QUIT":
+		w.WriteString("+OK\r\n")
+		return true
+	case "COMMAND":
+		// Sent by redis-cli on connect; an empty list disables its hints.
+		w.WriteString("*0\r\n")
+	default:
+		writeError(w, "ERR", fmt.Sprintf("unknown command '%s'", truncate(name)))
+	}
+	return false
+}
+
+// truncate shortens client input quoted in an error message.
+func truncate(s string) string {
+	if len(s) > 64 {
+		return s[:64] + "..."
+	}
+	return s
+}
+
+// writeGenerateError maps a generation error to an error reply. Clock
+// regressions and lost machine ID leases are reported as UNAVAILABLE.
+func (s *RESPServer) writeGenerateError(w *bufio.Writer, err error) {
+	prefix := "ERR"
+	if errors.Is(err, snowflake.ErrClockMovedBackwards) || s.gen.LeaseErr() != nil {
+		prefix = "UNAVAILABLE"
+	}
+	log.Printf("Error generating ID: %v", err)
+	writeError(w, prefix, err.Error())
+}
+
+// readCommand reads one command, either a RESP array of bulk strings or an
+// inline command of space-separated words. It returns errProtocol (wrapped)
+// for malformed requests and io.EOF when the client hung up.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxRESPArgs {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	args := make([]string, 0, max(n, 0))
+	for range n {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, fmt.Errorf("%w: expected '$', got %q", errProtocol, truncate(header))
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 || size > maxRESPLine {
+			return nil, fmt.Errorf("%w: invalid bulk length", errProtocol)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// readLine reads a line terminated by LF or CRLF, without the terminator.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxRESPLine {
+			return "", fmt.Errorf("%w: too big request", errProtocol)
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// writeInteger writes an integer reply.
+func writeInteger(w *bufio.Writer, n int64) {
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatInt(n, 10))
+	w.WriteString("\r\n")
+}
+
+// writeBulk writes a bulk string reply.
+func writeBulk(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+// writeError writes an error reply. Line breaks in msg would end the reply
+// early, so they are replaced by spaces.
+func writeError(w *bufio.Writer, prefix, msg string) {
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+	fmt.Fprintf(w, "-%s %s\r\n", prefix, msg)
+}
+
+// writeArityError reports a command called with the wrong number of arguments.
+func writeArityError(w *bufio.Writer, name string) {
+	writeError(w, "ERR", fmt.Sprintf("wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
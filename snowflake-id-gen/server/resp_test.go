@@ -0,0 +1,270 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// respError is an error reply.
+type respError string
+
+// startRESP serves a generator with machine ID 7 on a local port and returns
+// the server, its address and a channel receiving the result of Serve.
+func startRESP(t *testing.T, idleTimeout time.Duration) (*RESPServer, string, <-chan error) {
+	t.Helper()
+	gen, err := snowflake.NewSnowflake(7)
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	srv := NewRESPServer(gen, 100, idleTimeout)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return srv, l.Addr().String(), served
+}
+
+// dialRESP connects to addr and returns the connection and a reader of replies.
+func dialRESP(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+// command encodes args as a RESP array of bulk strings.
+func command(args ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return b.String()
+}
+
+// readReply reads one reply: a string for simple and bulk strings, a
+// respError for errors, an int64 for integers and a []any for arrays.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply line")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return respError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unknown reply %q", line)
+}
+
+// roundTrip sends req and reads one reply.
+func roundTrip(t *testing.T, conn net.Conn, r *bufio.Reader, req string) any {
+	t.Helper()
+	if _, err := io.WriteString(conn, req); err != nil {
+		t.Fatalf("writing %q: %v", req, err)
+	}
+	reply, err := readReply(r)
+	if err != nil {
+		t.Fatalf("reading reply to %q: %v", req, err)
+	}
+	return reply
+}
+
+func TestRESPCommands(t *testing.T) {
+	_, addr, _ := startRESP(t, 0)
+	conn, r := dialRESP(t, addr)
+
+	id, ok := roundTrip(t, conn, r, command("NEXTID")).(int64)
+	if !ok || id <= 0 {
+		t.Fatalf("NEXTID replied %v, want a positive integer", id)
+	}
+
+	ids, ok := roundTrip(t, conn, r, command("nextids", "3")).([]any)
+	if !ok || len(ids) != 3 {
+		t.Fatalf("NEXTIDS 3 replied %v, want three IDs", ids)
+	}
+	last := id
+	for _, v := range ids {
+		next, ok := v.(int64)
+		if !ok || next <= last {
+			t.Fatalf("NEXTIDS 3 replied %v, want integers increasing from %d", ids, id)
+		}
+		last = next
+	}
+
+	decoded, ok := roundTrip(t, conn, r, command("DECODE", strconv.FormatInt(id, 10))).([]any)
+	if !ok || len(decoded) != 14 {
+		t.Fatalf("DECODE replied %v, want seven field/value pairs", decoded)
+	}
+	fields := make(map[string]any)
+	for i := 0; i < len(decoded); i += 2 {
+		fields[decoded[i].(string)] = decoded[i+1]
+	}
+	if fields["id"] != strconv.FormatInt(id, 10) || fields["machine_id"] != int64(7) {
+		t.Errorf("DECODE replied %v, want ID %d on machine 7", fields, id)
+	}
+
+	// Inline commands work as sent by nc or telnet.
+	if reply := roundTrip(t, conn, r, "PING\r\n"); reply != "PONG" {
+		t.Errorf("inline PING replied %v, want PONG", reply)
+	}
+	if reply := roundTrip(t, conn, r, command("ECHO", "hello world")); reply != "hello world" {
+		t.Errorf("ECHO replied %v", reply)
+	}
+
+	errorCases := map[string]string{
+		command("NEXTIDS", "0"):      "ERR count must be",
+		command("NEXTIDS", "101"):    "ERR count must be",
+		command("NEXTID", "x"):       "ERR wrong number of arguments",
+		command("DECODE", "abc"):     "ERR invalid ID",
+		command("FLUSHALL"):          "ERR unknown command",
+		command("DECODE", "-1", "2"): "ERR wrong number of arguments",
+	}
+	for req, want := range errorCases {
+		reply, ok := roundTrip(t, conn, r, req).(respError)
+		if !ok || !strings.HasPrefix(string(reply), want) {
+			t.Errorf("%q replied %v, want an error starting with %q", req, reply, want)
+		}
+	}
+
+	if reply := roundTrip(t, conn, r, command("QUIT")); reply != "OK" {
+		t.Errorf("QUIT replied %v, want OK", reply)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("connection still open after QUIT: %v", err)
+	}
+}
+
+func TestRESPPipelining(t *testing.T) {
+	_, addr, _ := startRESP(t, 0)
+	conn, r := dialRESP(t, addr)
+
+	const n = 50
+	var req strings.Builder
+	for range n {
+		req.WriteString(command("NEXTID"))
+	}
+	req.WriteString("PING\r\n")
+	if _, err := io.WriteString(conn, req.String()); err != nil {
+		t.Fatalf("writing pipeline: %v", err)
+	}
+
+	var last int64
+	for i := range n {
+		reply, err := readReply(r)
+		if err != nil {
+			t.Fatalf("reading reply %d: %v", i, err)
+		}
+		id, ok := reply.(int64)
+		if !ok || id <= last {
+			t.Fatalf("reply %d is %v, want an ID greater than %d", i, reply, last)
+		}
+		last = id
+	}
+	if reply, err := readReply(r); err != nil || reply != "PONG" {
+		t.Errorf("last reply of the pipeline is %v (%v), want PONG", reply, err)
+	}
+}
+
+func TestRESPProtocolError(t *testing.T) {
+	_, addr, _ := startRESP(t, 0)
+	for _, req := range []string{"*1\r\n#4\r\n", "*1\r\n$4\r\nPINGxx", "*1000\r\n", "*1\r\n$99999999\r\n"} {
+		conn, r := dialRESP(t, addr)
+		reply, ok := roundTrip(t, conn, r, req).(respError)
+		if !ok || !strings.HasPrefix(string(reply), "ERR protocol error") {
+			t.Errorf("%q replied %v, want a protocol error", req, reply)
+		}
+		if _, err := r.ReadByte(); err != io.EOF {
+			t.Errorf("%q: connection still open after a protocol error: %v", req, err)
+		}
+	}
+}
+
+func TestRESPIdleTimeout(t *testing.T) {
+	_, addr, _ := startRESP(t, 50*time.Millisecond)
+	conn, r := dialRESP(t, addr)
+	if reply := roundTrip(t, conn, r, "PING\r\n"); reply != "PONG" {
+		t.Fatalf("PING replied %v, want PONG", reply)
+	}
+	start := time.Now()
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Fatalf("idle connection: got %v, want EOF", err)
+	}
+	if waited := time.Since(start); waited > 2*time.Second {
+		t.Errorf("idle connection closed after %v", waited)
+	}
+}
+
+func TestRESPShutdown(t *testing.T) {
+	srv, addr, served := startRESP(t, 0)
+	conn, r := dialRESP(t, addr)
+	if reply := roundTrip(t, conn, r, command("PING")); reply != "PONG" {
+		t.Fatalf("PING replied %v, want PONG", reply)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-served; !errors.Is(err, ErrRESPServerClosed) {
+		t.Errorf("Serve returned %v, want ErrRESPServerClosed", err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("idle connection after Shutdown: got %v, want EOF", err)
+	}
+	if c, err := net.Dial("tcp", addr); err == nil {
+		c.Close()
+		t.Error("server still accepts connections after Shutdown")
+	}
+}
@@ -0,0 +1,489 @@
+// Package simulate checks the uniqueness guarantees of Snowflake generators
+// across a simulated fleet.
+//
+// Run starts one in-process generator per node, each reading its own
+// snowflake.ManualClock. The clocks start skewed against each other, drift
+// at different rates and occasionally step backwards, as NTP-disciplined
+// clocks do. Machine IDs are either derived from simulated MAC addresses,
+// like MachineIDFromMAC does, or handed out exclusively, like the MySQL
+// leases of package lease. After the run every issued ID is checked for
+// global uniqueness and every node's IDs for monotonicity.
+//
+// Simulations are deterministic: the same Config (including Seed) always
+// produces the same Report.
+package simulate
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// ErrInvalidConfig is returned when a simulation cannot be run with its Config.
+var ErrInvalidConfig = errors.New("invalid simulation config")
+
+// MachineIDScheme selects how simulated nodes obtain their machine IDs.
+type MachineIDScheme int
+
+const (
+	// MachineIDsLeased gives every node its own machine ID (and its spare
+	// IDs), as a lease table does. This is the default.
+	MachineIDsLeased MachineIDScheme = iota
+	// MachineIDsMAC derives every node's machine ID from a random MAC
+	// address with snowflake.MachineIDFromHardwareAddr, so two nodes may
+	// end up with the same ID.
+	MachineIDsMAC
+)
+
+// machineIDSchemeNames maps schemes to the names used by String and
+// ParseMachineIDScheme.
+var machineIDSchemeNames = map[MachineIDScheme]string{
+	MachineIDsLeased: "leased",
+	MachineIDsMAC:    "mac",
+}
+
+// String returns the name of the scheme.
+func (m MachineIDScheme) String() string {
+	if name, ok := machineIDSchemeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("MachineIDScheme(%d)", int(m))
+}
+
+// ParseMachineIDScheme parses "leased" or "mac".
+func ParseMachineIDScheme(s string) (MachineIDScheme, error) {
+	for scheme, name := range machineIDSchemeNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return scheme, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown machine ID scheme %q (want leased or mac)", s)
+}
+
+// Defaults used when a Config field is left zero.
+const (
+	DefaultNodes      = 16
+	DefaultDuration   = 2 * time.Second
+	DefaultStep       = time.Millisecond
+	DefaultIDsPerStep = 4
+	// DefaultMaxCollisions bounds the collisions and regressions kept in a Report.
+	DefaultMaxCollisions = 20
+)
+
+// MaxIDs is the largest number of IDs a simulation may issue. Every ID is
+// kept in memory until the run has been checked.
+const MaxIDs = 10_000_000
+
+// Config describes a simulated fleet.
+type Config struct {
+	// The number of generators (DefaultNodes if zero).
+	Nodes int
+	// The layout shared by all generators (snowflake.DefaultLayout if zero).
+	Layout snowflake.Layout
+	// How the nodes obtain their machine IDs.
+	MachineIDs MachineIDScheme
+	// Spare machine IDs leased to every node for RollbackSwitchMachineID.
+	// Only supported with MachineIDsLeased.
+	SpareMachineIDs int
+	// How the generators react to their clock moving backwards.
+	Strategy snowflake.RollbackStrategy
+	// The rollback tolerance of the generators (the library default if zero).
+	Tolerance time.Duration
+
+	// The simulated wall-clock time of the start (one day after the layout's
+	// epoch if zero).
+	Start time.Time
+	// The simulated time span (DefaultDuration if zero).
+	Duration time.Duration
+	// The simulated time between two rounds (DefaultStep if zero). In every
+	// round each node advances its clock and then issues IDsPerStep IDs.
+	Step time.Duration
+	// The IDs every node issues per round (DefaultIDsPerStep if zero).
+	IDsPerStep int
+
+	// The largest offset of a node's clock from the true time at the start;
+	// offsets are uniform in [-MaxSkew, MaxSkew].
+	MaxSkew time.Duration
+	// The largest rate error of a node's clock as a fraction (1e-4 is 100
+	// ppm); rates are uniform in [-MaxDrift, MaxDrift].
+	MaxDrift float64
+	// The probability that a node's clock steps backwards in a round.
+	RollbackProbability float64
+	// The largest backwards step; steps are uniform in (0, MaxRollback].
+	MaxRollback time.Duration
+
+	// Seeds all randomness of the simulation.
+	Seed int64
+	// The collisions and regressions kept in the Report
+	// (DefaultMaxCollisions if zero); all of them are counted.
+	MaxCollisions int
+}
+
+// withDefaults returns cfg with zero fields replaced by their defaults.
+func (cfg Config) withDefaults() Config {
+	if cfg.Nodes == 0 {
+		cfg.Nodes = DefaultNodes
+	}
+	if cfg.Layout == (snowflake.Layout{}) {
+		cfg.Layout = snowflake.DefaultLayout
+	}
+	if cfg.Start.IsZero() {
+		cfg.Start = cfg.Layout.Epoch.Add(24 * time.Hour)
+	}
+	if cfg.Duration == 0 {
+		cfg.Duration = DefaultDuration
+	}
+	if cfg.Step == 0 {
+		cfg.Step = DefaultStep
+	}
+	if cfg.IDsPerStep == 0 {
+		cfg.IDsPerStep = DefaultIDsPerStep
+	}
+	if cfg.MaxCollisions == 0 {
+		cfg.MaxCollisions = DefaultMaxCollisions
+	}
+	return cfg
+}
+
+// validate reports the first problem with a Config that has its defaults applied.
+func (cfg Config) validate() error {
+	switch {
+	case cfg.Nodes < 0:
+		return fmt.Errorf("%w: %d nodes", ErrInvalidConfig, cfg.Nodes)
+	case cfg.Duration < 0 || cfg.Step < 0:
+		return fmt.Errorf("%w: negative duration or step", ErrInvalidConfig)
+	case cfg.IDsPerStep < 0:
+		return fmt.Errorf("%w: %d IDs per step", ErrInvalidConfig, cfg.IDsPerStep)
+	case cfg.MaxSkew < 0 || cfg.MaxRollback < 0:
+		return fmt.Errorf("%w: negative skew or rollback", ErrInvalidConfig)
+	case cfg.MaxDrift < 0 || cfg.MaxDrift >= 1:
+		return fmt.Errorf("%w: drift %v is not in [0, 1)", ErrInvalidConfig, cfg.MaxDrift)
+	case cfg.RollbackProbability < 0 || cfg.RollbackProbability > 1:
+		return fmt.Errorf("%w: rollback probability %v is not in [0, 1]", ErrInvalidConfig, cfg.RollbackProbability)
+	case cfg.SpareMachineIDs < 0:
+		return fmt.Errorf("%w: %d spare machine IDs", ErrInvalidConfig, cfg.SpareMachineIDs)
+	case cfg.SpareMachineIDs > 0 && cfg.MachineIDs != MachineIDsLeased:
+		return fmt.Errorf("%w: spare machine IDs need leased machine IDs", ErrInvalidConfig)
+	}
+	if cfg.Step > 0 {
+		if ids := int64(cfg.Nodes) * int64(cfg.Duration/cfg.Step) * int64(cfg.IDsPerStep); ids > MaxIDs {
+			return fmt.Errorf("%w: %d IDs to issue, at most %d are supported", ErrInvalidConfig, ids, MaxIDs)
+		}
+	}
+	if _, ok := machineIDSchemeNames[cfg.MachineIDs]; !ok {
+		return fmt.Errorf("%w: unknown machine ID scheme %v", ErrInvalidConfig, cfg.MachineIDs)
+	}
+	if err := cfg.Layout.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return nil
+}
+
+// Issue records one issued ID.
+type Issue struct {
+	// The node that issued the ID.
+	Node int `json:"node"`
+	// The machine ID the node was using.
+	MachineID int64 `json:"machine_id"`
+	// The true (simulated) time of the round the ID was issued in.
+	TrueTime time.Time `json:"true_time"`
+	// The node's clock reading when the ID was issued.
+	ClockTime time.Time `json:"clock_time"`
+}
+
+// Collision is an ID issued more than once.
+type Collision struct {
+	// The decoded ID.
+	Parts snowflake.Parts `json:"parts"`
+	// The first issue of the ID.
+	First Issue `json:"first"`
+	// A later issue of the same ID.
+	Second Issue `json:"second"`
+}
+
+// Regression is an ID that is not greater than the ID its node issued before.
+type Regression struct {
+	// The node's previous ID, decoded.
+	Previous snowflake.Parts `json:"previous"`
+	// The offending ID, decoded.
+	Parts snowflake.Parts `json:"parts"`
+	// Where the offending ID was issued.
+	Issue Issue `json:"issue"`
+}
+
+// Node summarises one simulated generator.
+type Node struct {
+	// The index of the node.
+	Node int `json:"node"`
+	// The machine ID the node started with.
+	MachineID int64 `json:"machine_id"`
+	// The spare machine IDs of the node.
+	SpareMachineIDs []int64 `json:"spare_machine_ids,omitempty"`
+	// The MAC address the machine ID was derived from (MachineIDsMAC only).
+	MAC string `json:"mac,omitempty"`
+	// The initial offset of the node's clock from the true time.
+	Skew time.Duration `json:"skew_ns"`
+	// The rate error of the node's clock.
+	Drift float64 `json:"drift"`
+	// The IDs the node issued.
+	IDs int `json:"ids"`
+	// The backwards steps injected into the node's clock.
+	InjectedRollbacks int `json:"injected_rollbacks"`
+	// The generator's own rollback counters.
+	Rollbacks snowflake.RollbackStats `json:"rollbacks"`
+	// Failed calls to GenerateID, such as ErrClockMovedBackwards under RollbackFail.
+	Failures int `json:"failures"`
+	// The last generation error, if any.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Report is the outcome of a simulation.
+type Report struct {
+	// The configuration the simulation ran with, defaults applied.
+	Config Config `json:"-"`
+	// Every node, in node order.
+	Nodes []Node `json:"nodes"`
+	// The IDs issued by all nodes.
+	IDs int `json:"ids"`
+	// The failed calls to GenerateID of all nodes.
+	Failures int `json:"failures"`
+	// The machine IDs claimed by more than one node (MachineIDsMAC only).
+	SharedMachineIDs []int64 `json:"shared_machine_ids"`
+	// The IDs issued more than once; Collisions keeps the first MaxCollisions.
+	CollisionCount int         `json:"collision_count"`
+	Collisions     []Collision `json:"collisions"`
+	// The IDs that broke a node's monotonicity; Regressions keeps the first MaxCollisions.
+	RegressionCount int          `json:"regression_count"`
+	Regressions     []Regression `json:"regressions"`
+}
+
+// OK reports whether all IDs were unique and every node's IDs increased.
+func (r Report) OK() bool {
+	return r.CollisionCount == 0 && r.RegressionCount == 0
+}
+
+// node is the state of one simulated generator.
+type node struct {
+	Node
+	// The node's clock and generator.
+	clock     *snowflake.ManualClock
+	generator *snowflake.Snowflake
+	// The node's source of randomness.
+	rng *rand.Rand
+	// The IDs issued by the node, in issue order.
+	ids []issued
+}
+
+// issued is an ID together with the round it was issued in.
+type issued struct {
+	id        int64
+	round     int
+	clockTime time.Time
+}
+
+// Run simulates the fleet described by cfg and checks the issued IDs.
+func Run(cfg Config) (Report, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.validate(); err != nil {
+		return Report{}, err
+	}
+
+	nodes, err := newNodes(cfg)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// Nodes share nothing, so they run concurrently.
+	rounds := int(cfg.Duration / cfg.Step)
+	var wg sync.WaitGroup
+	for _, n := range nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.run(cfg, rounds)
+		}()
+	}
+	wg.Wait()
+
+	return check(cfg, nodes), nil
+}
+
+// newNodes assigns machine IDs and clocks to the nodes of cfg.
+func newNodes(cfg Config) ([]*node, error) {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	layout := cfg.Layout
+
+	// Leased machine IDs are handed out in order, each node taking its
+	// spares right after its own ID.
+	perNode := int64(1 + cfg.SpareMachineIDs)
+	if cfg.MachineIDs == MachineIDsLeased && int64(cfg.Nodes)*perNode > layout.MaxMachineID()+1 {
+		return nil, fmt.Errorf("%w: %d nodes with %d spares need %d machine IDs, the layout has %d",
+			ErrInvalidConfig, cfg.Nodes, cfg.SpareMachineIDs, int64(cfg.Nodes)*perNode, layout.MaxMachineID()+1)
+	}
+
+	nodes := make([]*node, cfg.Nodes)
+	for i := range nodes {
+		n := &node{Node: Node{Node: i}}
+		switch cfg.MachineIDs {
+		case MachineIDsMAC:
+			// A random address under a fixed vendor prefix, as in a fleet
+			// of identical machines.
+			mac := net.HardwareAddr{0x02, 0x42, 0xac, byte(rng.Intn(256)), byte(rng.Intn(256)), byte(rng.Intn(256))}
+			id, err := snowflake.MachineIDFromHardwareAddr(mac, layout)
+			if err != nil {
+				return nil, err
+			}
+			n.MachineID = id
+			n.MAC = mac.String()
+		default:
+			n.MachineID = int64(i) * perNode
+			for j := int64(1); j < perNode; j++ {
+				n.SpareMachineIDs = append(n.SpareMachineIDs, n.MachineID+j)
+			}
+		}
+
+		// Each node gets its own clock error and its own random source.
+		n.Skew = time.Duration((rng.Float64()*2 - 1) * float64(cfg.MaxSkew))
+		n.Drift = (rng.Float64()*2 - 1) * cfg.MaxDrift
+		n.rng = rand.New(rand.NewSource(rng.Int63()))
+		n.clock = snowflake.NewManualClock(cfg.Start.Add(n.Skew))
+
+		opts := []snowflake.Option{
+			snowflake.WithLayout(layout),
+			snowflake.WithClock(n.clock),
+			snowflake.WithRollbackStrategy(cfg.Strategy),
+		}
+		if cfg.Tolerance > 0 {
+			opts = append(opts, snowflake.WithRollbackTolerance(cfg.Tolerance))
+		}
+		if len(n.SpareMachineIDs) > 0 {
+			opts = append(opts, snowflake.WithSpareMachineIDs(n.SpareMachineIDs...))
+		}
+		generator, err := snowflake.NewSnowflake(n.MachineID, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", i, err)
+		}
+		n.generator = generator
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+// run drives the node through the simulation.
+func (n *node) run(cfg Config, rounds int) {
+	// The node's clock runs fast or slow by its drift.
+	step := time.Duration(float64(cfg.Step) * (1 + n.Drift))
+	n.ids = make([]issued, 0, rounds*cfg.IDsPerStep)
+	for round := 0; round < rounds; round++ {
+		if round > 0 {
+			n.clock.Advance(step)
+		}
+		if cfg.MaxRollback > 0 && n.rng.Float64() < cfg.RollbackProbability {
+			// Step back by up to MaxRollback, never by zero.
+			n.clock.Advance(-time.Duration(n.rng.Int63n(int64(cfg.MaxRollback))) - 1)
+			n.InjectedRollbacks++
+		}
+		for range cfg.IDsPerStep {
+			id, err := n.generator.GenerateID()
+			if err != nil {
+				n.Failures++
+				n.LastError = err.Error()
+				continue
+			}
+			// ManualClock.Now has no side effects without a step.
+			n.ids = append(n.ids, issued{id: id, round: round, clockTime: n.clock.Now()})
+		}
+	}
+	n.IDs = len(n.ids)
+	n.Rollbacks = n.generator.RollbackStats()
+}
+
+// check looks for duplicate IDs across all nodes and for regressions within
+// each node.
+func check(cfg Config, nodes []*node) Report {
+	report := Report{
+		Config:           cfg,
+		Nodes:            make([]Node, len(nodes)),
+		SharedMachineIDs: []int64{},
+		Collisions:       []Collision{},
+		Regressions:      []Regression{},
+	}
+	layout := cfg.Layout
+	issue := func(n *node, i issued) Issue {
+		parts, _ := layout.Decode(i.id)
+		return Issue{
+			Node:      n.Node.Node,
+			MachineID: parts.MachineID,
+			TrueTime:  cfg.Start.Add(time.Duration(i.round) * cfg.Step),
+			ClockTime: i.clockTime,
+		}
+	}
+
+	// Remember the first issue of every ID.
+	type firstIssue struct {
+		node  *node
+		index int
+	}
+	seen := make(map[int64]firstIssue)
+	machineIDs := make(map[int64]int)
+	for idx, n := range nodes {
+		report.Nodes[idx] = n.Node
+		report.IDs += n.IDs
+		report.Failures += n.Failures
+		machineIDs[n.MachineID]++
+
+		// Key the previous ID by machine ID under RollbackSwitchMachineID:
+		// IDs issued after a switch sort before those issued just before
+		// it by design, but each machine ID's IDs must still increase.
+		previous := make(map[int64]issued)
+		for i, cur := range n.ids {
+			parts, _ := layout.Decode(cur.id)
+			key := int64(0)
+			if cfg.Strategy == snowflake.RollbackSwitchMachineID {
+				key = parts.MachineID
+			}
+			if prev, ok := previous[key]; ok && cur.id <= prev.id {
+				report.RegressionCount++
+				if len(report.Regressions) < cfg.MaxCollisions {
+					prevParts, _ := layout.Decode(prev.id)
+					report.Regressions = append(report.Regressions, Regression{
+						Previous: prevParts,
+						Parts:    parts,
+						Issue:    issue(n, cur),
+					})
+				}
+			}
+			previous[key] = cur
+
+			if first, ok := seen[cur.id]; ok {
+				report.CollisionCount++
+				if len(report.Collisions) < cfg.MaxCollisions {
+					report.Collisions = append(report.Collisions, Collision{
+						Parts:  parts,
+						First:  issue(first.node, first.node.ids[first.index]),
+						Second: issue(n, cur),
+					})
+				}
+				continue
+			}
+			seen[cur.id] = firstIssue{node: n, index: i}
+		}
+	}
+
+	for id, count := range machineIDs {
+		if count > 1 {
+			report.SharedMachineIDs = append(report.SharedMachineIDs, id)
+		}
+	}
+	sort.Slice(report.SharedMachineIDs, func(i, j int) bool {
+		return report.SharedMachineIDs[i] < report.SharedMachineIDs[j]
+	})
+	return report
+}
@@ -0,0 +1,119 @@
+package simulate
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+func TestRunSkewedLeasedFleet(t *testing.T) {
+	cfg := Config{
+		Nodes:      3,
+		MachineIDs: MachineIDsLeased,
+		Duration:   100 * time.Millisecond,
+		Step:       time.Millisecond,
+		IDsPerStep: 4,
+		MaxSkew:    50 * time.Millisecond,
+		MaxDrift:   1e-3,
+		Seed:       7,
+	}
+	report, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if report.IDs != 3*100*4 || report.Failures != 0 {
+		t.Errorf("IDs = %d, failures = %d, want 1200 and 0", report.IDs, report.Failures)
+	}
+	if !report.OK() || report.CollisionCount != 0 || report.RegressionCount != 0 {
+		t.Errorf("report has %d collisions and %d regressions, want none", report.CollisionCount, report.RegressionCount)
+	}
+	if len(report.SharedMachineIDs) != 0 {
+		t.Errorf("leased machine IDs are shared: %v", report.SharedMachineIDs)
+	}
+	for _, n := range report.Nodes {
+		if n.MachineID != int64(n.Node) {
+			t.Errorf("node %d leased machine ID %d", n.Node, n.MachineID)
+		}
+		if n.Skew < -cfg.MaxSkew || n.Skew > cfg.MaxSkew {
+			t.Errorf("node %d has skew %v outside ±%v", n.Node, n.Skew, cfg.MaxSkew)
+		}
+	}
+}
+
+func TestRunRollbacksUnderRollbackFail(t *testing.T) {
+	cfg := Config{
+		Nodes:               4,
+		MachineIDs:          MachineIDsLeased,
+		Strategy:            snowflake.RollbackFail,
+		Duration:            50 * time.Millisecond,
+		Step:                time.Millisecond,
+		IDsPerStep:          2,
+		RollbackProbability: 1,
+		MaxRollback:         5 * time.Millisecond,
+		Seed:                3,
+	}
+	report, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if !report.OK() {
+		t.Errorf("report has %d collisions and %d regressions, want none", report.CollisionCount, report.RegressionCount)
+	}
+	if report.IDs+report.Failures != 4*50*2 {
+		t.Errorf("IDs + failures = %d, want every one of the 400 calls", report.IDs+report.Failures)
+	}
+	if report.Failures == 0 {
+		t.Error("no call failed although the clocks stepped back every round")
+	}
+	for _, n := range report.Nodes {
+		if n.InjectedRollbacks != 50 {
+			t.Errorf("node %d: %d injected rollbacks, want one per round", n.Node, n.InjectedRollbacks)
+		}
+		if uint64(n.Failures) != n.Rollbacks.Failures {
+			t.Errorf("node %d: %d failed calls but %d failed rollbacks", n.Node, n.Failures, n.Rollbacks.Failures)
+		}
+	}
+}
+
+func TestRunMACCollisions(t *testing.T) {
+	cfg := Config{
+		Nodes:         200,
+		MachineIDs:    MachineIDsMAC,
+		Duration:      20 * time.Millisecond,
+		Step:          time.Millisecond,
+		IDsPerStep:    2,
+		MaxCollisions: 5,
+		Seed:          1,
+	}
+	report, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// 200 nodes on 1024 machine IDs share one with near certainty, and
+	// without skew nodes sharing an ID issue the same IDs.
+	if len(report.SharedMachineIDs) == 0 || report.CollisionCount == 0 || report.OK() {
+		t.Fatalf("shared machine IDs %v and %d collisions, want both", report.SharedMachineIDs, report.CollisionCount)
+	}
+	if len(report.Collisions) != cfg.MaxCollisions {
+		t.Errorf("report keeps %d collisions, want MaxCollisions = %d", len(report.Collisions), cfg.MaxCollisions)
+	}
+	for _, c := range report.Collisions {
+		if c.First.MachineID != c.Second.MachineID || c.First.Node == c.Second.Node {
+			t.Errorf("collision %d between nodes %d and %d with machine IDs %d and %d",
+				c.Parts.ID, c.First.Node, c.Second.Node, c.First.MachineID, c.Second.MachineID)
+		}
+	}
+
+	// The same seed replays the same fleet.
+	again, err := Run(cfg)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if !reflect.DeepEqual(report.Nodes, again.Nodes) || report.CollisionCount != again.CollisionCount {
+		t.Error("two runs with the same seed differ")
+	}
+}
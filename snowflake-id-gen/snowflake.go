@@ -1,32 +1,30 @@
-package main
+// Package snowflake implements a Twitter Snowflake style generator of unique,
+// time-ordered 64-bit IDs.
+//
+// By default an ID is composed of a 41-bit millisecond timestamp relative to
+// a custom epoch, a 10-bit machine ID and a 12-bit per-millisecond sequence
+// number. Other splits, epochs and tick durations can be selected with a
+// Layout.
+// A Snowflake generator is safe for concurrent use.
+package snowflake
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
-	"net"
-	"os"
-	"strconv"
 	"sync"
 	"time"
 )
 
-// Define constants for bit allocation.
+// Define constants for the bit allocation of DefaultLayout.
 const (
 	// Number of bits allocated for the machine ID.
 	machineIDBits uint8 = 10
 	// Number of bits allocated for the sequence number.
 	sequenceBits uint8 = 12
 
-	// Calculate the maximum possible machine ID (2^10 - 1 = 1023).
-	maxMachineID int64 = -1 ^ (-1 << machineIDBits)
-	// Calculate the maximum possible sequence number (2^12 - 1 = 4095).
-	maxSequence int64 = -1 ^ (-1 << sequenceBits)
-
-	// Define the bit shift amount for the timestamp component.
-	timestampShift = machineIDBits + sequenceBits // 10 + 12 = 22
-	// Define the bit shift amount for the machine ID component.
-	machineIDShift = sequenceBits // 12
+	// MaxMachineID is the maximum possible machine ID (2^10 - 1 = 1023).
+	MaxMachineID int64 = -1 ^ (-1 << machineIDBits)
 
 	// Define a custom epoch (January 1, 2024, 00:00:00 UTC) in milliseconds.
 	// You can adjust this epoch to your needs. Using a more recent epoch
@@ -43,27 +41,84 @@ type Snowflake struct {
 	lastTimestamp int64
 	// The unique ID of this machine/generator instance.
 	machineID int64
-	// The sequence number within the current millisecond.
+	// The sequence number within the current tick.
 	sequence int64
+
+	// The bit layout and epoch of generated IDs.
+	layout Layout
+	// The time source.
+	clock Clock
+	// The epoch and tick in nanoseconds, cached from layout.
+	base timeBase
+	// The maximum sequence number, cached from layout.
+	maxSequence int64
+	// The maximum timestamp, cached from layout.
+	maxTimestamp int64
+	// The bit shift amount for the timestamp component.
+	timestampShift uint8
+	// The bit shift amount for the machine ID component.
+	machineIDShift uint8
+
+	// How to react when the clock moves backwards.
+	rollbackStrategy RollbackStrategy
+	// The largest regression absorbed by waiting or borrowing.
+	rollbackTolerance time.Duration
+	// Machine IDs RollbackSwitchMachineID may switch to.
+	spareMachineIDs []int64
+	// The last timestamp issued by each machine ID that has been switched away from.
+	machineIDLast map[int64]int64
+	// Whether IDs are currently issued ahead of the clock (RollbackBorrow).
+	borrowing bool
+	// Called for every detected clock regression.
+	onRollback func(RollbackEvent)
+	// Counters of clock regressions.
+	rollbackStats RollbackStats
+	// Receives instrumentation events, if set.
+	observer Observer
+	// The lease on machineID, if the machine ID is leased.
+	lease MachineIDLease
+	// Durable storage of the high-water mark, if checkpointing is enabled.
+	checkpoint Checkpointer
+	// How far ahead of the clock checkpoints reserve timestamps.
+	checkpointInterval time.Duration
+	// The timestamp up to which (exclusive) the checkpoint covers IDs.
+	reservedUntil int64
+	// Warns once the remaining lifetime of the layout runs low, if set.
+	lifetime *lifetimeWarning
+}
+
+// Option configures optional behaviour of a Snowflake generator.
+type Option func(*Snowflake)
+
+// WithLayout makes the generator use the given bit layout and epoch instead
+// of DefaultLayout.
+func WithLayout(l Layout) Option {
+	return func(s *Snowflake) {
+		s.layout = l
+	}
 }
 
-// Error returned when the clock moves backwards.
+// ErrClockMovedBackwards is returned when the clock moves backwards.
 var ErrClockMovedBackwards = errors.New("clock moved backwards, refusing to generate ID")
 
-// Error returned when an invalid machine ID is provided.
+// ErrSequenceExhausted is returned by TryGenerateID when the sequence of the
+// current tick is used up and an ID would only be available in the next tick.
+var ErrSequenceExhausted = errors.New("sequence exhausted for the current tick")
+
+// ErrEpochExhausted is returned once the timestamp no longer fits into the
+// layout's timestamp bits and would overflow into the sign bit.
+var ErrEpochExhausted = errors.New("timestamp exhausted for the layout's epoch")
+
+// ErrInvalidMachineID is returned when an invalid machine ID is provided.
 var ErrInvalidMachineID = errors.New("invalid machine ID")
 
-// Error returned when no suitable network interface is found for machine ID generation.
+// ErrNoSuitableInterface is returned when no suitable network interface is found for machine ID generation.
 var ErrNoSuitableInterface = errors.New("no suitable network interface found for machine ID")
 
 // NewSnowflake creates and returns a new Snowflake generator instance.
-// It requires a machineID (0 to maxMachineID).
-func NewSnowflake(machineID int64) (*Snowflake, error) {
-	// Validate the provided machineID.
-	if machineID < 0 || machineID > maxMachineID {
-		// Return an error if the machineID is out of the valid range.
-		return nil, fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidMachineID, machineID, maxMachineID)
-	}
+// It requires a machineID between 0 and the layout's maximum machine ID
+// (MaxMachineID for DefaultLayout).
+func NewSnowflake(machineID int64, opts ...Option) (*Snowflake, error) {
 	// Create and initialize the Snowflake struct.
 	s := &Snowflake{
 		// Initialize lastTimestamp to -1 to indicate no IDs generated yet.
@@ -72,46 +127,190 @@ func NewSnowflake(machineID int64) (*Snowflake, error) {
 		machineID: machineID,
 		// Initialize sequence number to 0.
 		sequence: 0,
+		// Use the classic 41/10/12 split unless an option says otherwise.
+		layout: DefaultLayout,
+		// Read the system wall clock unless an option says otherwise.
+		clock: WallClock{},
+		// Fail fast on clock regressions unless an option says otherwise.
+		rollbackStrategy:  RollbackFail,
+		rollbackTolerance: DefaultRollbackTolerance,
+		machineIDLast:     make(map[int64]int64),
 		// The mutex is implicitly initialized (zero value is usable).
 	}
+	// Apply the caller supplied options.
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// Validate the layout before deriving anything from it.
+	if err := s.layout.Validate(); err != nil {
+		return nil, err
+	}
+	// Validate the provided machineID against the layout.
+	if machineID < 0 || machineID > s.layout.MaxMachineID() {
+		// Return an error if the machineID is out of the valid range.
+		return nil, fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidMachineID, machineID, s.layout.MaxMachineID())
+	}
+	// A leased machine ID must be the one the generator uses.
+	if s.lease != nil && s.lease.MachineID() != machineID {
+		return nil, fmt.Errorf("%w: %d is not the leased machine ID %d", ErrInvalidMachineID, machineID, s.lease.MachineID())
+	}
+	// Spare machine IDs must fit the layout as well.
+	for _, spare := range s.spareMachineIDs {
+		if spare < 0 || spare > s.layout.MaxMachineID() {
+			return nil, fmt.Errorf("%w: spare %d is not between 0 and %d", ErrInvalidMachineID, spare, s.layout.MaxMachineID())
+		}
+	}
+	// The checkpoint only records the history of the active machine ID, so
+	// a restart could reuse timestamps a spare had already issued.
+	if s.checkpoint != nil && (s.rollbackStrategy == RollbackSwitchMachineID || len(s.spareMachineIDs) > 0) {
+		return nil, errors.New("checkpoints cannot be combined with RollbackSwitchMachineID or spare machine IDs")
+	}
+
+	// Cache the values used on every call to GenerateID.
+	s.base = newTimeBase(s.layout)
+	s.maxSequence = s.layout.MaxSequence()
+	s.maxTimestamp = s.layout.MaxTimestamp()
+	s.timestampShift = s.layout.MachineIDBits() + s.layout.SequenceBits
+	s.machineIDShift = s.layout.SequenceBits
+	if s.lifetime != nil {
+		s.lifetime.init(s.layout)
+	}
+
+	// Never go below the high-water mark of a previous run.
+	if s.checkpoint != nil {
+		if err := s.loadCheckpoint(); err != nil {
+			return nil, err
+		}
+	}
 	// Return the pointer to the new Snowflake instance and no error.
 	return s, nil
 }
 
+// Layout returns the bit layout and epoch used by the generator.
+func (s *Snowflake) Layout() Layout {
+	return s.layout
+}
+
+// MachineID returns the machine ID embedded in generated IDs. It only
+// changes when RollbackSwitchMachineID switches to a spare machine ID.
+func (s *Snowflake) MachineID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.machineID
+}
+
 // GenerateID creates and returns a new unique 64-bit Snowflake ID.
+// If the clock moved backwards, the configured RollbackStrategy decides
+// whether an ID is still issued; by default ErrClockMovedBackwards is returned.
 func (s *Snowflake) GenerateID() (int64, error) {
+	return s.generate(context.Background(), true)
+}
+
+// GenerateIDContext is like GenerateID but gives up with ctx's error
+// (wrapped) once ctx is done, while waiting for the next tick or for the
+// clock to catch up under RollbackWait. Waiting for another caller holding
+// the generator is not interruptible, but lasts at most one such wait.
+func (s *Snowflake) GenerateIDContext(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.generate(ctx, true)
+}
+
+// TryGenerateID is like GenerateID but never waits for the clock: it returns
+// ErrSequenceExhausted when the current tick is used up, and
+// ErrClockMovedBackwards where RollbackWait would wait for the clock.
+func (s *Snowflake) TryGenerateID() (int64, error) {
+	return s.generate(context.Background(), false)
+}
+
+// generate issues one ID and reports it. Unless wait is set, it fails instead
+// of waiting for the clock.
+func (s *Snowflake) generate(ctx context.Context, wait bool) (int64, error) {
 	// Lock the mutex to ensure exclusive access to shared state (thread-safety).
 	s.mu.Lock()
-	// Defer unlocking the mutex so it's always released, even if errors occur.
-	defer s.mu.Unlock()
+	id, event, err := s.generateLocked(ctx, wait)
+	s.mu.Unlock()
+
+	// Surface clock regressions to the caller outside the lock.
+	if event != nil {
+		s.reportRollbacks(*event)
+	}
+	if err == nil {
+		if s.observer != nil {
+			s.observer.ObserveIDs(1)
+		}
+		s.lifetime.check(id >> s.timestampShift)
+	}
+	return id, err
+}
+
+// generateLocked issues the next ID. It must be called with the lock held and
+// also returns the clock regression event observed on the way, if any. It
+// waits for the clock, until ctx is done, only if wait is set.
+func (s *Snowflake) generateLocked(ctx context.Context, wait bool) (int64, *RollbackEvent, error) {
+	// Stop issuing IDs as soon as the machine ID lease is lost.
+	if err := s.checkLease(); err != nil {
+		return 0, nil, err
+	}
 
-	// Get the current time in milliseconds since the custom epoch.
-	currentTimestamp := time.Now().UnixMilli() - epoch
+	// Get the current time in ticks since the custom epoch.
+	currentTimestamp := s.currentTimestamp()
 
 	// Check for clock skew (clock moving backwards).
+	var event *RollbackEvent
 	if currentTimestamp < s.lastTimestamp {
-		// Log the event for monitoring purposes.
-		log.Printf("Clock moved backwards detected. Current: %d, Last: %d", currentTimestamp, s.lastTimestamp)
-		// Return an error as generating an ID could break monotonicity.
-		// In a production system, you might wait or have a more complex handling strategy.
-		return 0, ErrClockMovedBackwards
+		var err error
+		// Let the configured strategy decide which timestamp to continue with.
+		currentTimestamp, event, err = s.handleRollback(ctx, wait, currentTimestamp)
+		if err != nil {
+			return 0, event, err
+		}
+	} else {
+		// The clock has caught up; stop borrowing future sequence space.
+		s.borrowing = false
 	}
 
 	// If the current timestamp is the same as the last one...
 	if currentTimestamp == s.lastTimestamp {
-		// Increment the sequence number, applying a mask to wrap around if it exceeds maxSequence.
-		s.sequence = (s.sequence + 1) & maxSequence
-		// Check if the sequence number wrapped around (overflowed).
-		if s.sequence == 0 {
-			// Sequence overflowed, wait until the next millisecond.
-			currentTimestamp = s.tilNextMillis(s.lastTimestamp)
-			// Sequence is reset implicitly because it wrapped to 0 earlier.
+		// Check if the sequence number would overflow.
+		if s.sequence == s.maxSequence {
+			// Sequence overflowed, wait until the next tick. The state is
+			// left untouched if that is not possible.
+			if s.observer != nil {
+				s.observer.ObserveSequenceOverflow()
+			}
+			if !wait {
+				return 0, event, ErrSequenceExhausted
+			}
+			var err error
+			currentTimestamp, err = s.nextTimestamp(ctx, s.lastTimestamp)
+			if err != nil {
+				return 0, event, err
+			}
+			if err := s.checkExhausted(currentTimestamp); err != nil {
+				return 0, event, err
+			}
+			s.sequence = 0
+		} else {
+			// Increment the sequence number.
+			s.sequence++
 		}
 	} else {
-		// If it's a new millisecond, reset the sequence number to 0.
+		// Refuse timestamps that would overflow into the sign bit.
+		if err := s.checkExhausted(currentTimestamp); err != nil {
+			return 0, event, err
+		}
+		// If it's a new tick, reset the sequence number to 0.
 		s.sequence = 0
 	}
 
+	// Persist a new checkpoint window before issuing IDs beyond the current one.
+	if err := s.reserve(currentTimestamp); err != nil {
+		return 0, event, err
+	}
+
 	// Update the last timestamp.
 	s.lastTimestamp = currentTimestamp
 
@@ -119,184 +318,46 @@ func (s *Snowflake) GenerateID() (int64, error) {
 	// Shift timestamp left by the total bits of machine ID and sequence.
 	// Shift machine ID left by the bits of the sequence.
 	// Combine the shifted parts and the sequence using bitwise OR.
-	id := (currentTimestamp << timestampShift) |
-		(s.machineID << machineIDShift) |
+	id := (currentTimestamp << s.timestampShift) |
+		(s.machineID << s.machineIDShift) |
 		s.sequence
 
 	// Return the generated ID and no error.
-	return id, nil
-}
-
-// tilNextMillis blocks until the next millisecond after lastTs.
-// It returns the new timestamp (milliseconds since epoch).
-// This helper is called only when the sequence number overflows within a millisecond.
-func (s *Snowflake) tilNextMillis(lastTs int64) int64 {
-	// Get the current timestamp relative to the epoch.
-	timestamp := time.Now().UnixMilli() - epoch
-	// Loop as long as the current timestamp is less than or equal to the last timestamp.
-	for timestamp <= lastTs {
-		// Short sleep to avoid busy-waiting (optional, could also just re-read time).
-		// time.Sleep(time.Microsecond * 100) // Small sleep
-		// Re-fetch the current timestamp.
-		timestamp = time.Now().UnixMilli() - epoch
-	}
-	// Return the new, distinct timestamp.
-	return timestamp
+	return id, event, nil
 }
 
-// getMachineIDFromMAC attempts to derive a suitable machine ID from network interfaces.
-func getMachineIDFromMAC() (int64, error) {
-	// Get a list of all network interfaces on the system.
-	interfaces, err := net.Interfaces()
-	// Handle potential errors during interface retrieval.
-	if err != nil {
-		return 0, fmt.Errorf("failed to get network interfaces: %w", err)
-	}
-
-	// Iterate through the list of interfaces.
-	for _, iface := range interfaces {
-		// Skip loopback interfaces (e.g., 'lo').
-		if iface.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-		// Skip interfaces that are down.
-		if iface.Flags&net.FlagUp == 0 {
-			continue
-		}
-		// Get the hardware address (MAC address) of the interface.
-		mac := iface.HardwareAddr
-		// Skip interfaces without a valid MAC address (e.g., virtual interfaces).
-		if len(mac) == 0 {
-			continue
+// nextTimestamp returns the timestamp to use after the sequence overflowed
+// at lastTs. A borrowing generator moves on to the next tick right
+// away as long as it stays within the tolerance; otherwise it waits for the clock.
+func (s *Snowflake) nextTimestamp(ctx context.Context, lastTs int64) (int64, error) {
+	if s.borrowing {
+		now := s.currentTimestamp()
+		if s.withinTolerance(lastTs + 1 - now) {
+			return lastTs + 1, nil
 		}
-
-		// Use the last two bytes of the MAC address for the machine ID.
-		// This provides 16 bits of potential variation, which is more than
-		// the 10 bits needed, reducing collision probability locally.
-		// Note: This does NOT guarantee global uniqueness across datacenters
-		// or different network segments without careful management.
-		var derivedID int64
-		// Check if MAC address has at least 2 bytes.
-		if len(mac) >= 2 {
-			// Combine the last two bytes into an int64.
-			// Shift the second-to-last byte left by 8 bits and OR with the last byte.
-			derivedID = int64(mac[len(mac)-2])<<8 | int64(mac[len(mac)-1])
-		} else if len(mac) > 0 {
-			// If only one byte, use that. Less ideal.
-			derivedID = int64(mac[len(mac)-1])
-		} else {
-            // Should not happen due to earlier check, but defensive coding.
-            continue
-        }
-
-
-		// Ensure the derived ID fits within the allocated bits using a bitmask.
-		machineID := derivedID & maxMachineID
-
-		// Log the interface name, MAC, derived ID (before mask), and final machine ID.
-		log.Printf("Using interface: %s, MAC: %s, Derived ID (raw): %d, Final Machine ID: %d", iface.Name, mac.String(), derivedID, machineID)
-
-		// Return the first valid machine ID found.
-		return machineID, nil
 	}
-
-	// If no suitable interface was found after checking all, return an error.
-	return 0, ErrNoSuitableInterface
+	return s.tilNextTick(ctx, lastTs)
 }
 
-// main is the entry point of the program.
-func main() {
-	// Declare machineID variable.
-	var machineID int64
-	// Declare error variable for handling errors during setup.
-	var err error
-
-	// Check if a command-line argument was provided.
-	if len(os.Args) > 1 {
-		// Attempt to parse the first argument as a 64-bit integer (base 10).
-		machineID, err = strconv.ParseInt(os.Args[1], 10, 64)
-		// Handle potential parsing errors.
-		if err != nil {
-			// Log fatal error and exit if parsing fails.
-			log.Fatalf("Error parsing machine ID from command line argument '%s': %v", os.Args[1], err)
-		}
-		// Log the machine ID obtained from CLI.
-		log.Printf("Using machine ID from command line argument: %d", machineID)
-	} else {
-		// If no CLI argument, attempt to derive machine ID from MAC address.
-		log.Println("No command line argument for machine ID provided. Attempting to derive from MAC address...")
-		machineID, err = getMachineIDFromMAC()
-		// Handle potential errors during MAC address derivation.
-		if err != nil {
-			// Log fatal error and exit if derivation fails.
-			log.Fatalf("Error deriving machine ID: %v", err)
-		}
-	}
-
-	// Create a new Snowflake generator instance with the determined machineID.
-	generator, err := NewSnowflake(machineID)
-	// Handle potential errors during generator creation (e.g., invalid machine ID).
-	if err != nil {
-		// Log fatal error and exit if generator creation fails.
-		log.Fatalf("Error creating Snowflake generator: %v", err)
+// tilNextTick blocks until the next tick after lastTs or until ctx is done.
+// It returns the new timestamp (ticks since epoch).
+// This helper is called only when the sequence number overflows within a tick.
+func (s *Snowflake) tilNextTick(ctx context.Context, lastTs int64) (int64, error) {
+	// Get the current timestamp relative to the epoch.
+	timestamp := s.currentTimestamp()
+	// Report how long the caller was blocked, in real time.
+	if s.observer != nil && timestamp <= lastTs {
+		start := time.Now()
+		defer func() { s.observer.ObserveTickWait(time.Since(start)) }()
 	}
-
-	// Log successful generator creation.
-	log.Printf("Snowflake generator created successfully with Machine ID: %d", machineID)
-	log.Println("Generating 10 Snowflake IDs...")
-
-	// Generate and print 10 example IDs.
-	for i := 0; i < 10; i++ {
-		// Call GenerateID to get a new ID.
-		id, err := generator.GenerateID()
-		// Handle potential errors during ID generation (e.g., clock skew).
-		if err != nil {
-			// Log error if ID generation fails.
-			log.Printf("Error generating ID %d: %v", i+1, err)
-			// Continue to the next iteration in case of transient errors.
-			continue
+	// Loop as long as the current timestamp is less than or equal to the last timestamp.
+	for timestamp <= lastTs {
+		if err := PollClock(ctx, s.clock, s.base.duration(lastTs+1-timestamp)); err != nil {
+			return 0, fmt.Errorf("waiting for the next tick: %w", err)
 		}
-		// Print the generated ID.
-		fmt.Println(id)
-
-		// Optional: Add a small delay to see sequence numbers reset or increment.
-		// time.Sleep(time.Millisecond * 10)
+		// Re-fetch the current timestamp.
+		timestamp = s.currentTimestamp()
 	}
-
-	// Example: Generate IDs concurrently using goroutines
-	n := 20000
-	log.Println("\nGenerating 5 IDs concurrently...")
-	// Use a WaitGroup to wait for all goroutines to finish.
-	var wg sync.WaitGroup
-	// Launch n goroutines.
-	// Print current time with nanoseconds in human readable format.
-	log.Printf("Current time: %v", time.Now().Format(time.RFC3339Nano))
-	// Record the start time to measure concurrent generation duration.
-	start := time.Now()
-	for i := 0; i < n; i++ {
-		// Increment the WaitGroup counter for each goroutine.
-		wg.Add(1)
-		// Launch a goroutine.
-		go func(workerNum int) {
-			// Defer Done() to decrement the counter when the goroutine exits.
-			defer wg.Done()
-			// Generate an ID within the goroutine.
-			id, err := generator.GenerateID()
-			// Handle potential errors.
-			if err != nil {
-				fmt.Printf("[Worker %d] Error generating ID: %v", workerNum, err)
-				return
-			}
-			// Print the ID along with the worker number.
-			fmt.Printf("[Worker %d] Generated ID: %d", workerNum, id)
-		}(i) // Pass loop variable i to avoid closure issues
-	}	
-	// Wait for all goroutines launched by this loop to finish.
-	wg.Wait()
-	// Calculate and log the duration of concurrent generation.
-	log.Printf("Concurrent generation took: %v", time.Since(start))
-	// Print current time with nanoseconds in human readable format.
-	log.Printf("Current time: %v", time.Now().Format(time.RFC3339Nano))
-	// Indicate completion of concurrent generation.
-	log.Println("Concurrent generation finished.")
-}
\ No newline at end of file
+	// Return the new, distinct timestamp.
+	return timestamp, nil
+}
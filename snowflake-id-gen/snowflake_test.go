@@ -0,0 +1,102 @@
+package snowflake
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testStart is an arbitrary point after DefaultEpoch that the tests start
+// their manual clocks at.
+var testStart = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// newManualGenerator returns a generator reading a ManualClock set to
+// testStart.
+func newManualGenerator(t *testing.T, opts ...Option) (*Snowflake, *ManualClock) {
+	t.Helper()
+	clock := NewManualClock(testStart)
+	s, err := NewSnowflake(1, append([]Option{WithClock(clock)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	return s, clock
+}
+
+func TestGenerateIDClockMovedBackwards(t *testing.T) {
+	s, clock := newManualGenerator(t, WithRollbackStrategy(RollbackFail))
+
+	first, err := s.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID: %v", err)
+	}
+	clock.Advance(-5 * time.Millisecond)
+	if _, err := s.GenerateID(); !errors.Is(err, ErrClockMovedBackwards) {
+		t.Fatalf("GenerateID after a backwards jump: got %v, want ErrClockMovedBackwards", err)
+	}
+	if stats := s.RollbackStats(); stats.Events != 1 || stats.Failures != 1 {
+		t.Errorf("RollbackStats = %+v, want one failed event", stats)
+	}
+
+	// Once the clock is past the last timestamp again, IDs resume in order.
+	clock.Advance(6 * time.Millisecond)
+	next, err := s.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID after the clock caught up: %v", err)
+	}
+	if next <= first {
+		t.Errorf("ID after recovery %d is not greater than %d", next, first)
+	}
+}
+
+func TestGenerateIDSequenceOverflowWaitsForNextTick(t *testing.T) {
+	// 16 IDs per tick make the sequence easy to exhaust.
+	layout := Layout{TimestampBits: 49, WorkerBits: 10, SequenceBits: 4, Epoch: DefaultEpoch}
+	s, clock := newManualGenerator(t, WithLayout(layout))
+
+	var last int64
+	for i := int64(0); i <= layout.MaxSequence(); i++ {
+		id, err := s.GenerateID()
+		if err != nil {
+			t.Fatalf("GenerateID %d: %v", i, err)
+		}
+		last = id
+	}
+	if _, err := s.TryGenerateID(); !errors.Is(err, ErrSequenceExhausted) {
+		t.Fatalf("TryGenerateID with the sequence exhausted: got %v, want ErrSequenceExhausted", err)
+	}
+
+	// GenerateID sleeps on the manual clock instead of spinning.
+	id, err := s.GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID after overflow: %v", err)
+	}
+	lastParts, _ := s.Decode(last)
+	parts, _ := s.Decode(id)
+	if parts.Timestamp != lastParts.Timestamp+1 || parts.Sequence != 0 {
+		t.Errorf("ID after overflow has timestamp %d and sequence %d, want %d and 0",
+			parts.Timestamp, parts.Sequence, lastParts.Timestamp+1)
+	}
+	if got := clock.Now(); got != testStart.Add(time.Millisecond) {
+		t.Errorf("clock after overflow = %v, want one tick after the start", got)
+	}
+}
+
+func TestGenerateIDMonotonicAcrossAdvance(t *testing.T) {
+	s, clock := newManualGenerator(t)
+
+	var last int64 = -1
+	steps := []time.Duration{0, 0, 300 * time.Microsecond, 700 * time.Microsecond, time.Millisecond, 0, time.Second, 1500 * time.Microsecond}
+	for _, step := range steps {
+		clock.Advance(step)
+		for i := 0; i < 3; i++ {
+			id, err := s.GenerateID()
+			if err != nil {
+				t.Fatalf("GenerateID after advancing %v: %v", step, err)
+			}
+			if id <= last {
+				t.Fatalf("ID %d after advancing %v is not greater than %d", id, step, last)
+			}
+			last = id
+		}
+	}
+}
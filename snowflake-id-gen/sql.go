@@ -0,0 +1,39 @@
+package snowflake
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+)
+
+// Scan implements sql.Scanner so that IDs can be read straight from BIGINT
+// columns. It accepts int64, uint64 and decimal []byte or string values, as
+// returned by the various MySQL driver modes; NULL is rejected.
+func (id *ID) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("%w: cannot scan negative value %d", ErrInvalidID, v)
+		}
+		*id = ID(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return fmt.Errorf("%w: cannot scan %d, it exceeds the largest ID", ErrInvalidID, v)
+		}
+		*id = ID(v)
+	case []byte:
+		return id.UnmarshalText(v)
+	case string:
+		return id.UnmarshalText([]byte(v))
+	case nil:
+		return fmt.Errorf("%w: cannot scan NULL into an ID (use sql.Null[ID])", ErrInvalidID)
+	default:
+		return fmt.Errorf("%w: cannot scan %T into an ID", ErrInvalidID, src)
+	}
+	return nil
+}
+
+// Value implements driver.Valuer, storing the ID as an int64.
+func (id ID) Value() (driver.Value, error) {
+	return int64(id), nil
+}
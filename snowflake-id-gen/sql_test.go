@@ -0,0 +1,68 @@
+package snowflake
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestIDScan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  any
+		want ID
+		// Whether Scan must fail with ErrInvalidID.
+		wantErr bool
+	}{
+		{"int64", int64(370027206400839680), 370027206400839680, false},
+		{"int64 zero", int64(0), 0, false},
+		{"int64 negative", int64(-1), 0, true},
+		{"uint64", uint64(math.MaxInt64), math.MaxInt64, false},
+		{"uint64 overflow", uint64(math.MaxInt64) + 1, 0, true},
+		{"bytes", []byte("370027206400839680"), 370027206400839680, false},
+		{"bytes not a number", []byte("abc"), 0, true},
+		{"string", "42", 42, false},
+		{"string overflow", "9223372036854775808", 0, true},
+		{"string negative", "-42", 0, true},
+		{"nil", nil, 0, true},
+		{"float64", float64(42), 0, true},
+		{"time", time.Now(), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Failed scans leave the destination alone.
+			id := ID(7)
+			err := id.Scan(tt.src)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidID) {
+					t.Fatalf("Scan(%v): got %v, want ErrInvalidID", tt.src, err)
+				}
+				if id != 7 {
+					t.Errorf("failed Scan changed the ID to %d", id)
+				}
+				return
+			}
+			if err != nil || id != tt.want {
+				t.Fatalf("Scan(%v) = %d, %v, want %d", tt.src, id, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIDValue(t *testing.T) {
+	for _, id := range []ID{0, 370027206400839680, math.MaxInt64} {
+		v, err := id.Value()
+		if err != nil {
+			t.Fatalf("Value: %v", err)
+		}
+		n, ok := v.(int64)
+		if !ok || n != int64(id) {
+			t.Fatalf("Value of %d = %#v, want int64(%d)", id, v, int64(id))
+		}
+		var back ID
+		if err := back.Scan(v); err != nil || back != id {
+			t.Errorf("Scan(Value(%d)) = %d, %v", id, back, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Package ulid generates ULIDs (https://github.com/ulid/spec): 128-bit IDs
+// made of a 48-bit Unix millisecond timestamp and 80 random bits, written as
+// 26 Crockford base32 characters that sort like the IDs themselves.
+//
+// Generators are monotonic: within a millisecond the random part is
+// incremented rather than redrawn, so IDs from one generator strictly
+// increase. They read time through a snowflake.Clock and implement
+// snowflake.IDGenerator.
+package ulid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/monotonic"
+)
+
+// Scheme is the scheme name of ULIDs.
+const Scheme = "ulid"
+
+// encodedLen is the length of the text form of a ULID.
+const encodedLen = 26
+
+// crockfordAlphabet is the Crockford base32 alphabet, in ascending ASCII order.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ErrInvalidULID is returned when a string is not a valid ULID.
+var ErrInvalidULID = errors.New("invalid ULID")
+
+// ULID is a 128-bit ULID in big-endian byte order.
+type ULID [16]byte
+
+// String returns the 26 character Crockford base32 form of the ULID.
+func (u ULID) String() string {
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+	var out [encodedLen]byte
+	// Emit 5 bits at a time from the least significant end.
+	for i := encodedLen - 1; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// Time returns the timestamp of the ULID.
+func (u ULID) Time() time.Time {
+	var ms [8]byte
+	copy(ms[2:], u[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:]))).UTC()
+}
+
+// Parse parses the text form of a ULID, case-insensitively.
+func Parse(s string) (ULID, error) {
+	if len(s) != encodedLen {
+		return ULID{}, fmt.Errorf("%w: %q is not %d characters long", ErrInvalidULID, s, encodedLen)
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		v := decodeChar(s[i])
+		// The first character carries only 3 bits.
+		if v < 0 || (i == 0 && v > 7) {
+			return ULID{}, fmt.Errorf("%w: bad character %q in %q", ErrInvalidULID, s[i], s)
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+	var u ULID
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *ULID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// decodeChar returns the value of a Crockford base32 character, or -1.
+func decodeChar(c byte) int {
+	if 'a' <= c && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(crockfordAlphabet); i++ {
+		if crockfordAlphabet[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// Option configures a Generator.
+type Option func(*config)
+
+// config collects the options of New.
+type config struct {
+	clock   snowflake.Clock
+	entropy io.Reader
+}
+
+// WithClock makes the generator read time from clock instead of the system
+// wall clock.
+func WithClock(clock snowflake.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithEntropy makes the generator draw random bits from r instead of
+// crypto/rand.
+func WithEntropy(r io.Reader) Option {
+	return func(c *config) {
+		c.entropy = r
+	}
+}
+
+// Generator issues monotonic ULIDs. It is safe for concurrent use.
+type Generator struct {
+	m *monotonic.Generator
+}
+
+// New returns a ULID generator.
+func New(opts ...Option) *Generator {
+	c := config{clock: snowflake.WallClock{}, entropy: rand.Reader}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Generator{m: monotonic.New(c.clock, c.entropy, 80)}
+}
+
+// Generate returns a new ULID, waiting for the next millisecond if the
+// current one is used up.
+func (g *Generator) Generate() (ULID, error) {
+	return g.generate(context.Background(), true)
+}
+
+// GenerateContext is like Generate but gives up with ctx's error (wrapped)
+// once ctx is done.
+func (g *Generator) GenerateContext(ctx context.Context) (ULID, error) {
+	if err := ctx.Err(); err != nil {
+		return ULID{}, err
+	}
+	return g.generate(ctx, true)
+}
+
+// TryGenerate is like Generate but returns snowflake.ErrSequenceExhausted
+// instead of waiting.
+func (g *Generator) TryGenerate() (ULID, error) {
+	return g.generate(context.Background(), false)
+}
+
+// Scheme returns Scheme.
+func (g *Generator) Scheme() string {
+	return Scheme
+}
+
+// GenerateString returns a new ULID in text form.
+func (g *Generator) GenerateString(ctx context.Context) (string, error) {
+	u, err := g.GenerateContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// generate packs the next timestamp and counter into a ULID.
+func (g *Generator) generate(ctx context.Context, wait bool) (ULID, error) {
+	millis, hi, lo, err := g.m.Next(ctx, wait)
+	if err != nil {
+		return ULID{}, err
+	}
+	var u ULID
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(millis))
+	copy(u[:6], ms[2:])
+	binary.BigEndian.PutUint16(u[6:8], uint16(hi))
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, nil
+}
+
+// Ensure Generator implements snowflake.IDGenerator.
+var _ snowflake.IDGenerator = (*Generator)(nil)
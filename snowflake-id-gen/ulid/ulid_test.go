@@ -0,0 +1,143 @@
+package ulid
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// testStart is the time the tests start their clocks at.
+var testStart = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fillEntropy returns its byte for every random bit drawn.
+type fillEntropy byte
+
+func (e fillEntropy) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(e)
+	}
+	return len(p), nil
+}
+
+// generateN returns n ULIDs from g and fails the test on errors.
+func generateN(t *testing.T, g *Generator, n int) []ULID {
+	t.Helper()
+	ids := make([]ULID, n)
+	for i := range ids {
+		u, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate %d: %v", i, err)
+		}
+		ids[i] = u
+	}
+	return ids
+}
+
+// checkIncreasing fails the test unless ids and their text forms strictly
+// increase.
+func checkIncreasing(t *testing.T, ids []ULID) {
+	t.Helper()
+	for i := 1; i < len(ids); i++ {
+		if bytes.Compare(ids[i][:], ids[i-1][:]) <= 0 || ids[i].String() <= ids[i-1].String() {
+			t.Fatalf("ULID %d %s does not sort after %s", i, ids[i], ids[i-1])
+		}
+	}
+}
+
+func TestGenerateIncreasingWithinMillisecond(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	g := New(WithClock(clock))
+	ids := generateN(t, g, 1000)
+	checkIncreasing(t, ids)
+	for _, u := range ids {
+		if !u.Time().Equal(testStart) {
+			t.Fatalf("ULID %s has time %v, want %v", u, u.Time(), testStart)
+		}
+	}
+}
+
+func TestGenerateAfterClockRollback(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	g := New(WithClock(clock))
+	before := generateN(t, g, 10)
+	clock.Advance(-time.Second)
+	after := generateN(t, g, 10)
+
+	// ULIDs keep the last timestamp and keep increasing.
+	checkIncreasing(t, append(before, after...))
+	if got := after[9].Time(); !got.Equal(testStart) {
+		t.Errorf("ULID after a rollback has time %v, want %v", got, testStart)
+	}
+}
+
+func TestGenerateCounterExhausted(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	// The random part starts at its maximum, leaving no room to increment.
+	g := New(WithClock(clock), WithEntropy(fillEntropy(0xff)))
+	first, err := g.TryGenerate()
+	if err != nil {
+		t.Fatalf("TryGenerate: %v", err)
+	}
+	if _, err := g.TryGenerate(); !errors.Is(err, snowflake.ErrSequenceExhausted) {
+		t.Fatalf("TryGenerate with the counter exhausted: got %v, want ErrSequenceExhausted", err)
+	}
+
+	// Generate waits for the next millisecond instead.
+	next, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if want := testStart.Add(time.Millisecond); !next.Time().Equal(want) {
+		t.Errorf("ULID after exhaustion has time %v, want %v", next.Time(), want)
+	}
+	checkIncreasing(t, []ULID{first, next})
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	// The example of the ULID specification.
+	const example = "01ARZ3NDEKTSV4RRFFQ69G5FAV"
+	u, err := Parse(example)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", example, err)
+	}
+	if got := u.String(); got != example {
+		t.Errorf("String = %q, want %q", got, example)
+	}
+	if want := time.UnixMilli(1469922850259).UTC(); !u.Time().Equal(want) {
+		t.Errorf("Time = %v, want %v", u.Time(), want)
+	}
+	if lower, err := Parse(strings.ToLower(example)); err != nil || lower != u {
+		t.Errorf("Parse of the lowercase form = %s, %v, want %s", lower, err, u)
+	}
+
+	for _, u := range generateN(t, New(), 100) {
+		parsed, err := Parse(u.String())
+		if err != nil || parsed != u {
+			t.Fatalf("Parse(%q) = %s, %v", u.String(), parsed, err)
+		}
+	}
+
+	max := ULID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if got := max.String(); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("String of the largest ULID = %q", got)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"01ARZ3NDEKTSV4RRFFQ69G5FA",   // too short
+		"01ARZ3NDEKTSV4RRFFQ69G5FAVX", // too long
+		"01ARZ3NDEKTSV4RRFFQ69G5FAU",  // U is not in the alphabet
+		"01ARZ3NDEKTSV4RRFFQ69G5FA-",
+		"8ZZZZZZZZZZZZZZZZZZZZZZZZZ", // overflows 128 bits
+	} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidULID) {
+			t.Errorf("Parse(%q): got %v, want ErrInvalidULID", s, err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package uuidv7 generates version 7 UUIDs (RFC 9562): a 48-bit Unix
+// millisecond timestamp, the version and variant bits and 74 random bits,
+// written in the usual 8-4-4-4-12 hexadecimal form.
+//
+// Generators are monotonic: within a millisecond the 74 random bits are
+// incremented as one counter rather than redrawn (RFC 9562, section 6.2,
+// method 2), so UUIDs from one generator strictly increase. They read time
+// through a snowflake.Clock and implement snowflake.IDGenerator.
+package uuidv7
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+	"github.com/abkolan/snowflake-id-gen/internal/monotonic"
+)
+
+// Scheme is the scheme name of UUIDv7s.
+const Scheme = "uuidv7"
+
+// counterBits is the number of random bits: 12 in rand_a and 62 in rand_b.
+const counterBits = 74
+
+// ErrInvalidUUID is returned when a string is not a valid version 7 UUID.
+var ErrInvalidUUID = errors.New("invalid UUIDv7")
+
+// UUID is a 128-bit UUID in big-endian byte order.
+type UUID [16]byte
+
+// String returns the lowercase 8-4-4-4-12 hexadecimal form of the UUID.
+func (u UUID) String() string {
+	var out [36]byte
+	hex.Encode(out[0:8], u[0:4])
+	out[8] = '-'
+	hex.Encode(out[9:13], u[4:6])
+	out[13] = '-'
+	hex.Encode(out[14:18], u[6:8])
+	out[18] = '-'
+	hex.Encode(out[19:23], u[8:10])
+	out[23] = '-'
+	hex.Encode(out[24:], u[10:])
+	return string(out[:])
+}
+
+// Time returns the timestamp of the UUID.
+func (u UUID) Time() time.Time {
+	var ms [8]byte
+	copy(ms[2:], u[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:]))).UTC()
+}
+
+// Parse parses the 8-4-4-4-12 hexadecimal form of a version 7 UUID,
+// case-insensitively.
+func Parse(s string) (UUID, error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return UUID{}, fmt.Errorf("%w: %q is not of the form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx", ErrInvalidUUID, s)
+	}
+	var u UUID
+	// Decode the five groups into consecutive bytes.
+	groups := [][2]int{{0, 8}, {9, 13}, {14, 18}, {19, 23}, {24, 36}}
+	n := 0
+	for _, g := range groups {
+		m, err := hex.Decode(u[n:], []byte(s[g[0]:g[1]]))
+		if err != nil {
+			return UUID{}, fmt.Errorf("%w: %q: %v", ErrInvalidUUID, s, err)
+		}
+		n += m
+	}
+	if u[6]>>4 != 7 || u[8]>>6 != 0b10 {
+		return UUID{}, fmt.Errorf("%w: %q is not a version 7, RFC 9562 variant UUID", ErrInvalidUUID, s)
+	}
+	return u, nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// Option configures a Generator.
+type Option func(*config)
+
+// config collects the options of New.
+type config struct {
+	clock   snowflake.Clock
+	entropy io.Reader
+}
+
+// WithClock makes the generator read time from clock instead of the system
+// wall clock.
+func WithClock(clock snowflake.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithEntropy makes the generator draw random bits from r instead of
+// crypto/rand.
+func WithEntropy(r io.Reader) Option {
+	return func(c *config) {
+		c.entropy = r
+	}
+}
+
+// Generator issues monotonic version 7 UUIDs. It is safe for concurrent use.
+type Generator struct {
+	m *monotonic.Generator
+}
+
+// New returns a UUIDv7 generator.
+func New(opts ...Option) *Generator {
+	c := config{clock: snowflake.WallClock{}, entropy: rand.Reader}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Generator{m: monotonic.New(c.clock, c.entropy, counterBits)}
+}
+
+// Generate returns a new UUID, waiting for the next millisecond if the
+// current one is used up.
+func (g *Generator) Generate() (UUID, error) {
+	return g.generate(context.Background(), true)
+}
+
+// GenerateContext is like Generate but gives up with ctx's error (wrapped)
+// once ctx is done.
+func (g *Generator) GenerateContext(ctx context.Context) (UUID, error) {
+	if err := ctx.Err(); err != nil {
+		return UUID{}, err
+	}
+	return g.generate(ctx, true)
+}
+
+// TryGenerate is like Generate but returns snowflake.ErrSequenceExhausted
+// instead of waiting.
+func (g *Generator) TryGenerate() (UUID, error) {
+	return g.generate(context.Background(), false)
+}
+
+// Scheme returns Scheme.
+func (g *Generator) Scheme() string {
+	return Scheme
+}
+
+// GenerateString returns a new UUID in text form.
+func (g *Generator) GenerateString(ctx context.Context) (string, error) {
+	u, err := g.GenerateContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// generate packs the next timestamp and counter into a UUID.
+func (g *Generator) generate(ctx context.Context, wait bool) (UUID, error) {
+	millis, hi, lo, err := g.m.Next(ctx, wait)
+	if err != nil {
+		return UUID{}, err
+	}
+	// The top 12 counter bits become rand_a, the low 62 bits rand_b.
+	randA := hi<<2 | lo>>62
+	randB := lo & (1<<62 - 1)
+
+	var u UUID
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(millis))
+	copy(u[:6], ms[2:])
+	binary.BigEndian.PutUint16(u[6:8], 0x7000|uint16(randA))
+	binary.BigEndian.PutUint64(u[8:], 0b10<<62|randB)
+	return u, nil
+}
+
+// Ensure Generator implements snowflake.IDGenerator.
+var _ snowflake.IDGenerator = (*Generator)(nil)
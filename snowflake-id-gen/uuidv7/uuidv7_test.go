@@ -0,0 +1,149 @@
+package uuidv7
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abkolan/snowflake-id-gen"
+)
+
+// testStart is the time the tests start their clocks at.
+var testStart = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// fillEntropy returns its byte for every random bit drawn.
+type fillEntropy byte
+
+func (e fillEntropy) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(e)
+	}
+	return len(p), nil
+}
+
+// generateN returns n UUIDs from g and fails the test on errors.
+func generateN(t *testing.T, g *Generator, n int) []UUID {
+	t.Helper()
+	ids := make([]UUID, n)
+	for i := range ids {
+		u, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate %d: %v", i, err)
+		}
+		ids[i] = u
+	}
+	return ids
+}
+
+// checkUUIDs fails the test unless ids strictly increase, as bytes and as
+// text, and all carry the version and variant bits.
+func checkUUIDs(t *testing.T, ids []UUID) {
+	t.Helper()
+	for i, u := range ids {
+		if u[6]>>4 != 7 {
+			t.Fatalf("UUID %s has version %d, want 7", u, u[6]>>4)
+		}
+		if u[8]>>6 != 0b10 {
+			t.Fatalf("UUID %s has variant bits %02b, want 10", u, u[8]>>6)
+		}
+		if i > 0 && (bytes.Compare(u[:], ids[i-1][:]) <= 0 || u.String() <= ids[i-1].String()) {
+			t.Fatalf("UUID %d %s does not sort after %s", i, u, ids[i-1])
+		}
+	}
+}
+
+func TestGenerateIncreasingWithinMillisecond(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	ids := generateN(t, New(WithClock(clock)), 1000)
+	checkUUIDs(t, ids)
+	for _, u := range ids {
+		if !u.Time().Equal(testStart) {
+			t.Fatalf("UUID %s has time %v, want %v", u, u.Time(), testStart)
+		}
+	}
+}
+
+func TestGenerateCarriesIntoRandA(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	// rand_b starts at its maximum, so the next UUID carries into rand_a
+	// without touching the version or variant bits.
+	entropy := bytes.NewReader(append(make([]byte, 8), 0x3f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff))
+	checkUUIDs(t, generateN(t, New(WithClock(clock), WithEntropy(entropy)), 2))
+}
+
+func TestGenerateAfterClockRollback(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	g := New(WithClock(clock))
+	before := generateN(t, g, 10)
+	clock.Advance(-time.Second)
+	after := generateN(t, g, 10)
+
+	checkUUIDs(t, append(before, after...))
+	if got := after[9].Time(); !got.Equal(testStart) {
+		t.Errorf("UUID after a rollback has time %v, want %v", got, testStart)
+	}
+}
+
+func TestGenerateCounterExhausted(t *testing.T) {
+	clock := snowflake.NewManualClock(testStart)
+	g := New(WithClock(clock), WithEntropy(fillEntropy(0xff)))
+	first, err := g.TryGenerate()
+	if err != nil {
+		t.Fatalf("TryGenerate: %v", err)
+	}
+	if _, err := g.TryGenerate(); !errors.Is(err, snowflake.ErrSequenceExhausted) {
+		t.Fatalf("TryGenerate with the counter exhausted: got %v, want ErrSequenceExhausted", err)
+	}
+	next, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if want := testStart.Add(time.Millisecond); !next.Time().Equal(want) {
+		t.Errorf("UUID after exhaustion has time %v, want %v", next.Time(), want)
+	}
+	checkUUIDs(t, []UUID{first, next})
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	// The example of RFC 9562, appendix A.6.
+	const example = "017f22e2-79b0-7cc3-98c4-dc0c0c07398f"
+	u, err := Parse(example)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", example, err)
+	}
+	if got := u.String(); got != example {
+		t.Errorf("String = %q, want %q", got, example)
+	}
+	if want := time.UnixMilli(0x017f22e279b0).UTC(); !u.Time().Equal(want) {
+		t.Errorf("Time = %v, want %v", u.Time(), want)
+	}
+	if upper, err := Parse(strings.ToUpper(example)); err != nil || upper != u {
+		t.Errorf("Parse of the uppercase form = %s, %v, want %s", upper, err, u)
+	}
+
+	for _, u := range generateN(t, New(), 100) {
+		parsed, err := Parse(u.String())
+		if err != nil || parsed != u {
+			t.Fatalf("Parse(%q) = %s, %v", u.String(), parsed, err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"017f22e279b07cc398c4dc0c0c07398f",      // no dashes
+		"017f22e2-79b0-7cc3-98c4-dc0c0c07398",   // too short
+		"017f22e2-79b0-7cc3-98c4_dc0c0c07398f",  // misplaced separator
+		"017f22e2-79b0-7cc3-98c4-dc0c0c07398g",  // not hexadecimal
+		"017f22e2-79b0-4cc3-98c4-dc0c0c07398f",  // version 4
+		"017f22e2-79b0-7cc3-c8c4-dc0c0c07398f",  // Microsoft variant
+		"017f22e2-79b0-7cc3-98c4-dc0c0c07398f0", // too long
+	} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalidUUID) {
+			t.Errorf("Parse(%q): got %v, want ErrInvalidUUID", s, err)
+		}
+	}
+}